@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/command"
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+const cacheUsage = `Usage: gogen cache <command> [arguments] [options]
+
+Commands:
+  status         show the number of cache entries and the cache size
+  show <path>    show the fingerprint recorded for a source or output file
+  clear [glob]   remove all cache entries, or those whose source matches the glob
+                 relative to --dir
+  verify         rehash sources and outputs and report entries that drifted
+  prune          remove cache entries of directives that no longer exist under --dir
+
+Options are the same as for gogen and select the cache file.
+`
+
+// sizer 由能报告存储大小的缓存实现
+type sizer interface {
+	Size() (int64, error)
+}
+
+// runCache 实现 gogen cache 子命令，各子命令只通过 generator.Cache 接口访问缓存
+func runCache(args []string) {
+	if len(args) == 0 || !slices.Contains([]string{"status", "show", "clear", "verify", "prune"}, args[0]) {
+		log.Print(cacheUsage)
+		os.Exit(2)
+	}
+
+	// 位置参数可以出现在选项之前或之后
+	name, args := args[0], args[1:]
+	var positional []string
+	for len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional, args = append(positional, args[0]), args[1:]
+	}
+	cfg, opts := loadConfig("gogen cache "+name, args)
+	if cfg == nil {
+		return
+	}
+	positional = append(positional, opts.args...)
+
+	// 只有 clear 和 prune 修改缓存，其他子命令只读
+	sum, err := loadCache(cfg, name != "clear" && name != "prune")
+	if err != nil {
+		log.Fatalf("load cache failed: %v", err)
+	}
+
+	switch {
+	case name == "status" && len(positional) == 0:
+		cacheStatus(os.Stdout, cfg, sum)
+	case name == "show" && len(positional) == 1:
+		if !cacheShow(os.Stdout, cfg, sum, positional[0]) {
+			log.Fatalf("no cache entry for %s", positional[0])
+		}
+	case name == "clear" && len(positional) <= 1:
+		glob := ""
+		if len(positional) == 1 {
+			glob = positional[0]
+		}
+		cleared := cacheClear(sum, cfg.Dir, glob)
+		if err := sum.Save(); err != nil {
+			log.Fatalf("save cache failed: %v", err)
+		}
+		log.Printf("cleared %d cache entries", cleared)
+	case name == "verify" && len(positional) == 0:
+		drifts := cacheVerify(sum, generatorOptions(cfg, sum).Hasher)
+		for _, d := range drifts {
+			fmt.Printf("%s: %s (%s)\n", d.reason, rel(cfg.Dir, d.path), rel(cfg.Dir, d.key))
+		}
+		if len(drifts) > 0 {
+			log.Fatalf("%d cache entries drifted", len(drifts))
+		}
+		log.Println("cache is consistent with the files on disk")
+	case name == "prune" && len(positional) == 0:
+		runCachePrune(cfg, opts, sum)
+	default:
+		log.Print(cacheUsage)
+		os.Exit(2)
+	}
+}
+
+// cacheStatus 输出缓存的记录数、输出文件数、源文件已不存在的记录数和存储大小
+func cacheStatus(w io.Writer, cfg *config.Config, c generator.Cache) {
+	var entries, outside, outputs, missing int
+	for _, key := range c.Keys() {
+		entry, _ := c.Get(key)
+		entries++
+		outputs += len(entry.Outputs)
+		source, _ := generator.SplitKey(key)
+		if !generator.Within(cfg.Dir, source) {
+			outside++
+		}
+		if _, err := os.Stat(source); os.IsNotExist(err) {
+			missing++
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "cache:\t%s\n", cacheFile(cfg))
+	if s, ok := c.(sizer); ok {
+		if size, err := s.Size(); err == nil {
+			fmt.Fprintf(tw, "size:\t%s\n", formatSize(size))
+		}
+	}
+	fmt.Fprintf(tw, "entries:\t%d (%d outside %s)\n", entries, outside, cfg.Dir)
+	fmt.Fprintf(tw, "outputs:\t%d\n", outputs)
+	fmt.Fprintf(tw, "missing sources:\t%d\n", missing)
+	tw.Flush()
+}
+
+// cacheShow 输出源文件中各指令的缓存记录，path 为输出文件时输出生成它的指令的记录。
+// 没有匹配的记录时返回 false
+func cacheShow(w io.Writer, cfg *config.Config, c generator.Cache, path string) bool {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	found := false
+	for _, key := range c.Keys() {
+		entry, _ := c.Get(key)
+		_, isOutput := entry.Outputs[abs]
+		if source, _ := generator.SplitKey(key); key != abs && source != abs && !isOutput {
+			continue
+		}
+		found = true
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "%s\n", rel(cfg.Dir, key))
+		fmt.Fprintf(tw, "  source:\t%s\n", entry.Source)
+		fmt.Fprintf(tw, "  command:\t%s\n", entry.Command)
+		fmt.Fprintf(tw, "  tool:\t%s\n", entry.Tool)
+		for _, output := range generator.SortedKeys(entry.Outputs) {
+			fmt.Fprintf(tw, "  output:\t%s\t%s\n", rel(cfg.Dir, output), entry.Outputs[output])
+		}
+		tw.Flush()
+	}
+	return found
+}
+
+// cacheClear 删除源文件相对于 dir 的路径匹配 glob 的记录，glob 为空时删除所有记录，返回删除的记录数
+func cacheClear(c generator.Cache, dir, glob string) int {
+	cleared := 0
+	for _, key := range c.Keys() {
+		source, _ := generator.SplitKey(key)
+		if glob != "" && !command.MatchGlob(glob, filepath.ToSlash(rel(dir, source))) {
+			continue
+		}
+		c.Delete(key)
+		cleared++
+	}
+	return cleared
+}
+
+// drift 记录一个与磁盘上的文件不一致的缓存记录
+type drift struct {
+	key    string
+	path   string
+	reason string
+}
+
+// cacheVerify 重新计算每条记录中源文件和输出文件的哈希，返回与记录不一致的文件。
+// 指令和工具的变化需要查找指令，由 gogen plan 报告
+func cacheVerify(c generator.Cache, hasher generator.FileHasher) []drift {
+	var drifts []drift
+	for _, key := range c.Keys() {
+		entry, _ := c.Get(key)
+		source, _ := generator.SplitKey(key)
+		if reason := verifyFile(hasher, source, entry.Source); reason != "" {
+			drifts = append(drifts, drift{key: key, path: source, reason: "source " + reason})
+		}
+		for _, output := range generator.SortedKeys(entry.Outputs) {
+			if reason := verifyFile(hasher, output, entry.Outputs[output]); reason != "" {
+				drifts = append(drifts, drift{key: key, path: output, reason: "output " + reason})
+			}
+		}
+	}
+	return drifts
+}
+
+// verifyFile 返回文件与记录的哈希不一致的原因，一致时返回空字符串
+func verifyFile(hasher generator.FileHasher, path, hash string) string {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return "missing"
+	}
+	if hasher.IsChanged(path, hash) {
+		return "modified"
+	}
+	return ""
+}
+
+// runCachePrune 删除 --dir 下已不存在的指令的缓存记录，不执行任何命令
+func runCachePrune(cfg *config.Config, opts *options, c generator.Cache) {
+	if !prunable(opts) {
+		log.Fatalf("cache %s may be shared with other generators, run prune with -c all", cacheFile(cfg))
+	}
+
+	pruned, err := generator.New(generatorOptions(cfg, c)).Prune(context.Background(), cfg.Dir)
+	if err != nil {
+		log.Fatalf("prune cache failed: %v", err)
+	}
+	for _, key := range pruned {
+		fmt.Println(rel(cfg.Dir, key))
+	}
+	if err := c.Save(); err != nil {
+		log.Fatalf("save cache failed: %v", err)
+	}
+	log.Printf("pruned %d stale cache entries", len(pruned))
+}
+
+// formatSize 以易读的单位返回字节数
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/cache"
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+	"github.com/llamazing-cn/go-generate-manager/pkg/hash"
+)
+
+func TestCacheCommands(t *testing.T) {
+	dir := t.TempDir()
+	hasher := hash.NewSourceHasher()
+	write := func(path, content string) string {
+		t.Helper()
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	hashOf := func(path string) string {
+		t.Helper()
+		h, err := hasher.Hash(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	a := write("a/a.go", "package a\n")
+	aOut := write("a/gen.go", "package a // generated\n")
+	b := write("b/b.go", "package b\n")
+	bOut := write("b/gen.go", "package b // generated\n")
+
+	cfg := &config.Config{Dir: dir, Cache: filepath.Join(dir, "gogen.sum")}
+	newSum := func() *cache.FileCache {
+		sum := cache.NewFileCacheWithOptions(cfg.Cache, cache.Options{Root: dir})
+		sum.Set(a+"#3", generator.Entry{Source: hashOf(a), Command: "cmd-a", Outputs: map[string]string{aOut: hashOf(aOut)}})
+		sum.Set(b+"#3", generator.Entry{Source: hashOf(b), Command: "cmd-b", Outputs: map[string]string{bOut: hashOf(bOut)}})
+		return sum
+	}
+
+	t.Run("show", func(t *testing.T) {
+		var out bytes.Buffer
+		if !cacheShow(&out, cfg, newSum(), aOut) {
+			t.Fatal("expected entry for output file")
+		}
+		for _, want := range []string{"a/a.go#3", "command:  cmd-a", "a/gen.go"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("expected %q in output:\n%s", want, out.String())
+			}
+		}
+		if cacheShow(&out, cfg, newSum(), filepath.Join(dir, "c.go")) {
+			t.Error("expected no entry for unknown file")
+		}
+	})
+
+	t.Run("clear", func(t *testing.T) {
+		sum := newSum()
+		if n := cacheClear(sum, dir, "b/**"); n != 1 {
+			t.Errorf("expected 1 entry cleared, got %d", n)
+		}
+		if _, ok := sum.Get(a + "#3"); !ok {
+			t.Error("expected entry outside glob to be kept")
+		}
+		if n := cacheClear(sum, dir, ""); n != 1 || len(sum.Keys()) != 0 {
+			t.Errorf("expected all entries cleared, got %d, left %q", n, sum.Keys())
+		}
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		sum := newSum()
+		if drifts := cacheVerify(sum, hasher); len(drifts) != 0 {
+			t.Fatalf("expected no drift, got %+v", drifts)
+		}
+
+		write("a/gen.go", "package a // edited\n")
+		if err := os.Remove(b); err != nil {
+			t.Fatal(err)
+		}
+		want := []drift{
+			{key: a + "#3", path: aOut, reason: "output modified"},
+			{key: b + "#3", path: b, reason: "source missing"},
+		}
+		drifts := cacheVerify(sum, hasher)
+		if len(drifts) != len(want) {
+			t.Fatalf("expected %+v, got %+v", want, drifts)
+		}
+		for i := range want {
+			if drifts[i] != want[i] {
+				t.Errorf("drift %d: expected %+v, got %+v", i, want[i], drifts[i])
+			}
+		}
+	})
+}
+
+func TestCacheFile(t *testing.T) {
+	cfg := &config.Config{
+		Dir:        "/repo",
+		Output:     "/repo/gen",
+		Generators: []config.Generator{{Name: "mockgen"}, {Name: "stringer"}},
+	}
+	// 与选择的生成工具无关，总是使用 gogen.sum，早期版本的 {name}.sum 只用于导入
+	for _, generators := range [][]config.Generator{cfg.Generators, cfg.Generators[:1]} {
+		cfg.Generators = generators
+		if got, want := cacheFile(cfg), filepath.Join("/repo/gen", "gogen.sum"); got != want {
+			t.Errorf("got cache file %s, want %s", got, want)
+		}
+	}
+	if got, want := legacyCacheFiles(cfg), []string{filepath.Join("/repo/gen", "mockgen.sum")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got legacy cache files %q, want %q", got, want)
+	}
+
+	cfg.Cache = "/repo/.gogen.sum"
+	if got := cacheFile(cfg); got != cfg.Cache {
+		t.Errorf("got cache file %s, want configured %s", got, cfg.Cache)
+	}
+	if got := legacyCacheFiles(cfg); got != nil {
+		t.Errorf("expected no legacy cache files with a configured cache, got %q", got)
+	}
+}
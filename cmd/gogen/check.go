@@ -0,0 +1,400 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/cache"
+	"github.com/llamazing-cn/go-generate-manager/pkg/command"
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+	"github.com/llamazing-cn/go-generate-manager/pkg/graph"
+)
+
+// staleFile 记录一个与重新生成的结果不一致的文件
+type staleFile struct {
+	path      string // 工作区中的路径
+	generated string // 临时目录中重新生成的路径
+	directive string // 生成该文件的指令
+	state     string // missing、modified 或 extra
+	diff      string // 工作区文件与重新生成的文件之间的差异
+}
+
+// runCheck 实现 gogen check 子命令：检查生成的文件是否是最新的，不修改工作区。
+// 缓存命中的指令视为最新，其余指令在临时目录中重新生成后与工作区对比，
+// 有文件不一致时列出文件和差异并以状态 1 退出
+func runCheck(cfg *config.Config) {
+	ctx, stop := interruptContext(context.Background())
+	defer stop()
+	ctx, cancel := withTimeout(ctx, cfg)
+	defer cancel()
+	stale, err := check(ctx, cfg)
+	if err != nil {
+		var multi *generator.MultiError
+		if errors.As(err, &multi) {
+			fmt.Fprint(os.Stderr, failures(cfg.Dir, multi))
+			log.Fatalf("check failed: %d directives failed", len(multi.Errors))
+		}
+		log.Fatalf("check failed: %v", err)
+	}
+
+	if len(stale) == 0 {
+		log.Println("generated files are up to date")
+		return
+	}
+
+	for _, f := range stale {
+		fmt.Printf("%s: %s (%s)\n", f.state, rel(cfg.Dir, f.path), f.directive)
+	}
+	for _, f := range stale {
+		fmt.Print(f.diff)
+	}
+	log.Printf("%d generated files are stale, run gogen to update them", len(stale))
+	os.Exit(1)
+}
+
+// check 返回所有过期的生成文件
+func check(ctx context.Context, cfg *config.Config) ([]staleFile, error) {
+	sum, err := loadCache(cfg, true)
+	if err != nil {
+		return nil, fmt.Errorf("load cache: %w", err)
+	}
+
+	// 1. 通过缓存找出需要重新生成的指令
+	report, err := generator.New(generatorOptions(cfg, sum)).Plan(ctx, cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	root := moduleRoot(cfg.Dir)
+	planned := make(map[string]bool)
+	// needed 为需要检查的指令所在目录和声明的输出，复制工作区时必须包含
+	var needed []string
+	var outside []error
+	for _, result := range report.Results {
+		if result.Status != generator.StatusPlanned {
+			continue
+		}
+		cmd := result.Command
+		planned[directiveKey(root, cmd)] = true
+		needed = append(needed, filepath.Dir(cmd.GetFilePath()))
+		for _, output := range cmd.Outputs() {
+			if !generator.Within(root, output) && (cfg.Output == "" || !generator.Within(cfg.Output, output)) {
+				outside = append(outside, fmt.Errorf("%s: output %s is outside the module root %s",
+					generator.CommandLocation(cmd), output, root))
+				continue
+			}
+			needed = append(needed, output)
+		}
+	}
+	// 临时副本只包含模块根目录和输出目录，其他位置的输出会写入真实的文件，无法检查
+	if len(outside) > 0 {
+		return nil, fmt.Errorf("cannot check directives writing outside the overlay: %w", errors.Join(outside...))
+	}
+	if len(planned) == 0 {
+		return nil, nil
+	}
+
+	// 2. 把工作区复制到临时目录，只重新生成需要检查的指令
+	tmp, err := os.MkdirTemp("", "gogen-check-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	o, err := newOverlay(root, tmp, cfg.Output, needed)
+	if err != nil {
+		return nil, fmt.Errorf("create overlay: %w", err)
+	}
+
+	ocfg := *cfg
+	ocfg.Dir = o.toOverlay(cfg.Dir)
+	if cfg.Output != "" {
+		ocfg.Output = o.toOverlay(cfg.Output)
+	}
+	opts := generatorOptions(&ocfg, cache.NewFileCache(filepath.Join(tmp, "check.sum")))
+	overlayRoot := o.toOverlay(root)
+	opts.Finder = &filterFinder{
+		finder: command.NewFinderWithOptions(finderOptions(&ocfg)),
+		keep: func(cmd generator.Command) bool {
+			return planned[directiveKey(overlayRoot, cmd)]
+		},
+	}
+	if g, err := graph.Load(ctx, ocfg.Dir); err == nil {
+		opts.Graph = g
+	}
+
+	report, err = generator.New(opts).Run(ctx, ocfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 对比重新生成的文件与工作区中的文件
+	seen := make(map[string]bool)
+	var stale []staleFile
+	for _, result := range report.Results {
+		changes := result.Changes
+		for _, paths := range [][]string{changes.Created, changes.Modified, changes.Deleted, result.Outputs} {
+			for _, generated := range paths {
+				if seen[generated] {
+					continue
+				}
+				seen[generated] = true
+
+				path := o.fromOverlay(generated)
+				state, err := compareFiles(path, generated)
+				if err != nil {
+					return nil, err
+				}
+				if state != "" {
+					f := staleFile{
+						path:      path,
+						generated: generated,
+						directive: directiveKey(overlayRoot, result.Command),
+						state:     state,
+					}
+					f.diff = diff(cfg.Dir, f)
+					stale = append(stale, f)
+				}
+			}
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].path < stale[j].path })
+	return stale, nil
+}
+
+// directiveKey 返回指令相对于 root 的标识，用于在工作区和临时目录之间对应指令
+func directiveKey(root string, cmd generator.Command) string {
+	return fmt.Sprintf("%s:%d", filepath.ToSlash(rel(root, cmd.GetFilePath())), cmd.GetLine())
+}
+
+// compareFiles 对比工作区文件和重新生成的文件，一致时返回空字符串
+func compareFiles(path, generated string) (string, error) {
+	want, wantErr := os.ReadFile(generated)
+	got, gotErr := os.ReadFile(path)
+	switch {
+	case wantErr != nil && !os.IsNotExist(wantErr):
+		return "", wantErr
+	case gotErr != nil && !os.IsNotExist(gotErr):
+		return "", gotErr
+	case wantErr != nil && gotErr != nil:
+		return "", nil
+	case gotErr != nil:
+		return "missing", nil
+	case wantErr != nil:
+		return "extra", nil
+	case !bytes.Equal(want, got):
+		return "modified", nil
+	}
+	return "", nil
+}
+
+// diff 用 diff -u 对比工作区文件与重新生成的文件，系统中没有 diff 时返回空字符串
+func diff(dir string, f staleFile) string {
+	if _, err := exec.LookPath("diff"); err != nil {
+		return ""
+	}
+	name := filepath.ToSlash(rel(dir, f.path))
+	a, b := f.path, f.generated
+	if f.state == "missing" {
+		a = os.DevNull
+	}
+	if f.state == "extra" {
+		b = os.DevNull
+	}
+	// 有差异时 diff 以状态 1 退出，只使用其输出
+	out, _ := exec.Command("diff", "-u", "--label", "a/"+name, "--label", "b/"+name, a, b).Output()
+	return string(out)
+}
+
+// moduleRoot 返回 dir 所在的 Go 模块根目录，不在模块中时返回 dir 本身
+func moduleRoot(dir string) string {
+	for d := dir; ; {
+		if _, err := os.Stat(filepath.Join(d, "go.mod")); err == nil {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+// filterFinder 只返回 keep 为 true 的指令
+type filterFinder struct {
+	finder generator.CommandFinder
+	keep   func(cmd generator.Command) bool
+}
+
+func (f *filterFinder) Find(dir string) ([]generator.Command, error) {
+	commands, err := f.finder.Find(dir)
+	if err != nil {
+		return nil, err
+	}
+	var kept []generator.Command
+	for _, cmd := range commands {
+		if f.keep(cmd) {
+			kept = append(kept, cmd)
+		}
+	}
+	return kept, nil
+}
+
+// overlay 是工作区在临时目录中的副本，输出目录不在工作区中时单独复制
+type overlay struct {
+	// dirs 记录工作区目录与副本目录的对应关系
+	dirs [][2]string
+}
+
+// newOverlay 复制模块根目录和输出目录，needed 中的路径及其所在目录总是复制，其余目录按 treeFilter 跳过或链接
+func newOverlay(root, tmp, output string, needed []string) (*overlay, error) {
+	o := &overlay{}
+	src := filepath.Join(tmp, "src")
+	filter := &treeFilter{ignored: gitIgnoredDirs(root), needed: needed}
+	if err := copyTree(root, src, filter); err != nil {
+		return nil, err
+	}
+	o.dirs = append(o.dirs, [2]string{root, src})
+
+	if output != "" && !generator.Within(root, output) {
+		dst := filepath.Join(tmp, "output")
+		if _, err := os.Stat(output); err == nil {
+			if err := copyTree(output, dst, nil); err != nil {
+				return nil, err
+			}
+		}
+		o.dirs = append(o.dirs, [2]string{output, dst})
+	}
+	return o, nil
+}
+
+func (o *overlay) toOverlay(path string) string {
+	return o.mapPath(path, 0, 1)
+}
+
+func (o *overlay) fromOverlay(path string) string {
+	return o.mapPath(path, 1, 0)
+}
+
+func (o *overlay) mapPath(path string, from, to int) string {
+	// 输出目录在后，优先匹配
+	for i := len(o.dirs) - 1; i >= 0; i-- {
+		if generator.Within(o.dirs[i][from], path) {
+			return filepath.Join(o.dirs[i][to], rel(o.dirs[i][from], path))
+		}
+	}
+	return path
+}
+
+// copyMode 为复制目录树时对目录的处理方式
+type copyMode int
+
+const (
+	copyDir copyMode = iota
+	skipDir
+	linkDir
+)
+
+// treeFilter 决定复制工作区时跳过或链接的目录：隐藏目录、node_modules 和 git 忽略的目录（通常是构建输出）被跳过，
+// 生成命令只会读取的 vendor 目录链接到原位置。指令所在目录和声明的输出所在的目录总是复制
+type treeFilter struct {
+	ignored map[string]bool
+	needed  []string
+}
+
+func (f *treeFilter) mode(path string) copyMode {
+	for _, p := range f.needed {
+		if generator.Within(path, p) || generator.Within(p, path) {
+			return copyDir
+		}
+	}
+	switch name := filepath.Base(path); {
+	case name == "vendor":
+		return linkDir
+	case strings.HasPrefix(name, "."), name == "node_modules", f.ignored[path]:
+		return skipDir
+	}
+	return copyDir
+}
+
+// gitIgnoredDirs 返回 root 下被 git 忽略的目录，root 不在 git 仓库中或没有安装 git 时返回 nil
+func gitIgnoredDirs(root string) map[string]bool {
+	out, err := exec.Command("git", "-C", root, "ls-files", "-z", "--others", "--ignored", "--exclude-standard", "--directory").Output()
+	if err != nil {
+		return nil
+	}
+	dirs := make(map[string]bool)
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if dir, ok := strings.CutSuffix(entry, "/"); ok {
+			dirs[filepath.Join(root, filepath.FromSlash(dir))] = true
+		}
+	}
+	return dirs
+}
+
+// copyTree 复制目录树，filter 为 nil 时复制所有内容
+func copyTree(src, dst string, filter *treeFilter) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel(src, path))
+		if d.IsDir() && path != src && filter != nil {
+			switch filter.mode(path) {
+			case skipDir:
+				return filepath.SkipDir
+			case linkDir:
+				if err := os.Symlink(path, target); err != nil {
+					return err
+				}
+				return filepath.SkipDir
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, perm fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/cache"
+	"github.com/llamazing-cn/go-generate-manager/pkg/command"
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+func TestCheck(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed, skipping tests")
+	}
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "a", "a.go")
+	output := filepath.Join(dir, "a", "gen.go")
+	writeSource := func(version string) {
+		t.Helper()
+		content := "package a\n\n//go:generate sh -c \"echo " + version + " > gen.go\"\n"
+		if err := os.WriteFile(source, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(source), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module demo\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeSource("v1")
+
+	cfg := &config.Config{
+		Dir:        dir,
+		Workers:    1,
+		Format:     formatText,
+		Generators: []config.Generator{{Name: "sh"}},
+	}
+	sum := cache.NewFileCache(cacheFile(cfg))
+	if err := generator.New(generatorOptions(cfg, sum)).Generate(context.Background(), dir); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+	if err := sum.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	stale, err := check(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("expected no stale files, got %+v", stale)
+	}
+
+	writeSource("v2")
+	stale, err = check(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(stale) != 1 || stale[0].path != output || stale[0].state != "modified" {
+		t.Fatalf("expected %s to be stale, got %+v", output, stale)
+	}
+	if _, err := exec.LookPath("diff"); err == nil && !strings.Contains(stale[0].diff, "+v2") {
+		t.Errorf("expected diff to contain the regenerated content, got %q", stale[0].diff)
+	}
+
+	// 检查不能修改工作区
+	if content, _ := os.ReadFile(output); string(content) != "v1\n" {
+		t.Errorf("expected working tree to be untouched, got %q", content)
+	}
+}
+
+func TestFilterFinder(t *testing.T) {
+	dir := t.TempDir()
+	content := "package a\n//go:generate echo a\n//go:generate echo b\n"
+	if err := os.WriteFile(filepath.Join(dir, "a.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	finder := &filterFinder{
+		finder: command.NewFinder("echo"),
+		keep: func(cmd generator.Command) bool {
+			return directiveKey(dir, cmd) == "a.go:3"
+		},
+	}
+	commands, err := finder.Find(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands) != 1 || commands[0].String() != "echo b" {
+		t.Errorf("unexpected commands %v", commands)
+	}
+}
+
+func TestCheckOutputOutsideModule(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "mod")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module demo\n\ngo 1.21\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	content := "package a\n\n//go:generate echo -out=../shared/gen.go\n"
+	if err := os.WriteFile(filepath.Join(root, "a.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Dir:         root,
+		Workers:     1,
+		Format:      formatText,
+		Generators:  []config.Generator{{Name: "echo"}},
+		OutputFlags: map[string][]string{"echo": {"out"}},
+	}
+	_, err := check(context.Background(), cfg)
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(dir, "shared", "gen.go")) {
+		t.Errorf("expected output outside the module to be refused, got %v", err)
+	}
+}
+
+func TestCopyTreeFilter(t *testing.T) {
+	src := t.TempDir()
+	for _, path := range []string{".git/HEAD", "node_modules/x/index.js", "vendor/m/m.go", "pkg/a.go", "bin/app", "tools/.cache/gen/a.go"} {
+		path = filepath.Join(src, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "src")
+	filter := &treeFilter{
+		ignored: map[string]bool{filepath.Join(src, "bin"): true},
+		needed:  []string{filepath.Join(src, "tools", ".cache", "gen")},
+	}
+	if err := copyTree(src, dst, filter); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{
+		".git":                  false,
+		"bin":                   false,
+		"node_modules":          false,
+		"pkg/a.go":              true,
+		"tools/.cache/gen/a.go": true,
+		"vendor/m/m.go":         true,
+	} {
+		_, err := os.Stat(filepath.Join(dst, filepath.FromSlash(path)))
+		if got := err == nil; got != want {
+			t.Errorf("%s: copied %v, want %v", path, got, want)
+		}
+	}
+	if info, err := os.Lstat(filepath.Join(dst, "vendor")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected vendor to be linked, got %v", err)
+	}
+}
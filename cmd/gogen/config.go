@@ -0,0 +1,323 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+)
+
+const usage = `Usage: gogen [options]
+       gogen plan [options]
+       gogen check [options]
+       gogen watch [options]
+       gogen cache <status|show|clear|verify|prune> [options]
+       gogen config print [options]
+
+Options:
+  -d, --dir     <path>     directory to generate files
+  -c, --cmd     <command>  command to use for code generation, repeatable or
+                           comma separated; "all" runs every directive
+  -o, --output  <path>     directory to output files, mirroring the source tree
+  -w, --workers <number>   number of worker goroutines (default: min(CPUs*2, 8))
+      --output-flag <tool=flag>
+                           extra flag declaring the output of a tool (repeatable)
+      --format  <format>   output format: text, json (NDJSON events) or junit
+                           (default: text)
+      --timeout <duration> stop the whole run after the duration, e.g. 10m
+      --command-timeout <duration>
+                           stop a directive that runs longer than the duration;
+                           overridden per tool in the config file or with a
+                           //gogen:timeout annotation
+      --dry-run            same as gogen plan
+      --exit-code          with plan, exit with status 1 if any directive would run
+      --config  <path>     config file (default: gogen.yaml, gogen.yml or gogen.toml
+                           found by walking up from --dir)
+  -h, --help              show this help message
+
+Commands:
+  plan                     show which directives would run and why, without
+                           executing anything
+  check                    regenerate stale directives in a temporary copy of the
+                           tree and fail if any generated file differs
+  watch                    run once, then rerun affected directives whenever files
+                           under --dir change (Linux only)
+  cache status             show the number of cache entries and the cache size
+  cache show <path>        show the fingerprint recorded for a source or output file
+  cache clear [glob]       remove all cache entries, or those whose source matches
+                           the glob relative to --dir
+  cache verify             rehash sources and outputs and report entries that drifted
+  cache prune              remove cache entries of directives that no longer exist
+                           under --dir; a full run does the same automatically
+  config print             print the effective configuration
+
+Flags override values from the config file.
+
+Example:
+  gogen -d ./src -c mockgen -o ./gen
+  gogen --dir=./src --cmd=mockgen --output=./gen --workers=4
+  gogen -c sqlc -o ./gen --output-flag sqlc=out
+  gogen -c mockgen,stringer -c protoc
+  gogen -c all
+  gogen -c all --format junit > gogen.xml
+  gogen -c all --timeout 10m --command-timeout 1m
+  gogen plan -c all --exit-code
+`
+
+// allGenerators 表示运行所有 //go:generate 指令
+const allGenerators = "all"
+
+type options struct {
+	dir         string
+	cmds        cmdList
+	output      string
+	workers     int
+	outputFlags outputFlags
+	format      string
+	configFile  string
+	timeout     time.Duration
+	cmdTimeout  time.Duration
+	dryRun      bool
+	exitCode    bool
+	help        bool
+
+	// args 为选项之后的位置参数
+	args []string
+
+	// set 记录命令行中显式设置的参数
+	set map[string]bool
+}
+
+// cmdList 解析可重复、可用逗号分隔的 -c 参数
+type cmdList []string
+
+func (l *cmdList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *cmdList) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(*l, name) {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+// outputFlags 解析重复出现的 --output-flag tool=flag 参数
+type outputFlags map[string][]string
+
+func (f outputFlags) String() string {
+	var pairs []string
+	for tool, names := range f {
+		for _, name := range names {
+			pairs = append(pairs, tool+"="+name)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f outputFlags) Set(value string) error {
+	tool, name, ok := strings.Cut(value, "=")
+	if !ok || tool == "" || name == "" {
+		return fmt.Errorf("invalid output flag %q, expected tool=flag", value)
+	}
+	f[tool] = append(f[tool], strings.TrimLeft(name, "-"))
+	return nil
+}
+
+func defaultWorkers() int {
+	workers := runtime.NumCPU() * 2
+	if workers > 8 {
+		workers = 8
+	}
+	return workers
+}
+
+func parseFlags(name string, args []string) *options {
+	opts := &options{outputFlags: make(outputFlags), set: make(map[string]bool)}
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+
+	fs.StringVar(&opts.dir, "d", "...", "directory to generate files (use ... for current directory)")
+	fs.Var(&opts.cmds, "c", "command to use for code generation (repeatable, comma separated, or all)")
+	fs.StringVar(&opts.output, "o", "", "directory to output files (default: same as source)")
+	fs.IntVar(&opts.workers, "w", defaultWorkers(), "number of worker goroutines")
+	fs.BoolVar(&opts.help, "h", false, "show help message")
+
+	fs.StringVar(&opts.dir, "dir", "...", "directory to generate files (use ... for current directory)")
+	fs.Var(&opts.cmds, "cmd", "command to use for code generation (repeatable, comma separated, or all)")
+	fs.StringVar(&opts.output, "output", "", "directory to output files (default: same as source)")
+	fs.IntVar(&opts.workers, "workers", defaultWorkers(), "number of worker goroutines")
+	fs.BoolVar(&opts.help, "help", false, "show help message")
+	fs.Var(opts.outputFlags, "output-flag", "extra flag declaring the output of a tool, as tool=flag")
+	fs.StringVar(&opts.format, "format", formatText, "output format: text, json or junit")
+	fs.StringVar(&opts.configFile, "config", "", "config file")
+	fs.DurationVar(&opts.timeout, "timeout", 0, "timeout of the whole run")
+	fs.DurationVar(&opts.cmdTimeout, "command-timeout", 0, "timeout of a single directive")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "show which directives would run without executing them")
+	fs.BoolVar(&opts.exitCode, "exit-code", false, "with plan, exit with status 1 if any directive would run")
+
+	fs.Usage = func() {
+		log.Print(usage)
+	}
+
+	fs.Parse(args)
+	fs.Visit(func(f *flag.Flag) {
+		opts.set[f.Name] = true
+	})
+	opts.args = fs.Args()
+
+	if opts.help {
+		fs.Usage()
+		return nil
+	}
+
+	if opts.workers < 1 {
+		log.Printf("Warning: invalid worker count %d, using 1 instead", opts.workers)
+		opts.workers = 1
+	}
+
+	return opts
+}
+
+// isSet 判断参数的短格式或长格式是否被显式设置
+func (o *options) isSet(names ...string) bool {
+	for _, name := range names {
+		if o.set[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig 解析命令行参数并与配置文件合并，返回生效的配置和命令行参数，参数无效时返回 nil
+func loadConfig(name string, args []string) (*config.Config, *options) {
+	opts := parseFlags(name, args)
+	if opts == nil {
+		return nil, nil
+	}
+
+	if opts.dir == "..." {
+		var err error
+		opts.dir, err = os.Getwd()
+		if err != nil {
+			log.Fatalf("get working directory failed: %v", err)
+		}
+	}
+
+	path := opts.configFile
+	if path == "" {
+		var err error
+		path, err = config.Discover(opts.dir)
+		if err != nil {
+			log.Fatalf("discover config file failed: %v", err)
+		}
+	}
+
+	cfg := &config.Config{}
+	if path != "" {
+		var err error
+		cfg, err = config.Load(path)
+		if err != nil {
+			log.Fatalf("load config failed: %v", err)
+		}
+	}
+
+	if err := opts.apply(cfg); err != nil {
+		log.Fatalf("resolve config failed: %v", err)
+	}
+	if !validate(cfg) {
+		log.Print(usage)
+		return nil, nil
+	}
+	return cfg, opts
+}
+
+// apply 用显式设置的命令行参数覆盖配置
+func (o *options) apply(cfg *config.Config) error {
+	if o.isSet("d", "dir") || cfg.Dir == "" {
+		dir, err := filepath.Abs(o.dir)
+		if err != nil {
+			return err
+		}
+		cfg.Dir = dir
+	}
+
+	if o.isSet("o", "output") {
+		output, err := filepath.Abs(o.output)
+		if err != nil {
+			return err
+		}
+		cfg.Output = output
+	}
+
+	if o.isSet("w", "workers") || cfg.Workers <= 0 {
+		cfg.Workers = o.workers
+	}
+
+	if o.isSet("timeout") {
+		cfg.Timeout = o.timeout
+	}
+
+	if o.isSet("command-timeout") {
+		cfg.CommandTimeout = o.cmdTimeout
+	}
+
+	if o.isSet("format") || cfg.Format == "" {
+		cfg.Format = o.format
+	}
+
+	if len(o.outputFlags) > 0 {
+		if cfg.OutputFlags == nil {
+			cfg.OutputFlags = make(map[string][]string)
+		}
+		for tool, names := range o.outputFlags {
+			cfg.OutputFlags[tool] = append(cfg.OutputFlags[tool], names...)
+		}
+	}
+
+	if len(o.cmds) > 0 {
+		generators := make([]config.Generator, 0, len(o.cmds))
+		for _, name := range o.cmds {
+			g, ok := cfg.Generator(name)
+			if !ok {
+				g = config.Generator{Name: name}
+			}
+			generators = append(generators, g)
+		}
+		// 运行所有指令时保留配置中各工具的设置
+		if slices.Contains(o.cmds, allGenerators) {
+			for _, g := range cfg.Generators {
+				if !slices.Contains(o.cmds, g.Name) {
+					generators = append(generators, g)
+				}
+			}
+		}
+		cfg.Generators = generators
+	}
+
+	return nil
+}
+
+func validate(cfg *config.Config) bool {
+	if len(cfg.Generators) == 0 {
+		log.Println("Error: required flag -cmd must be set or generators declared in the config file")
+		return false
+	}
+	if cfg.Timeout < 0 || cfg.CommandTimeout < 0 {
+		log.Println("Error: timeouts must not be negative")
+		return false
+	}
+	if !slices.Contains([]string{formatText, formatJSON, formatJUnit}, cfg.Format) {
+		log.Printf("Error: unknown format %q, expected text, json or junit", cfg.Format)
+		return false
+	}
+	return true
+}
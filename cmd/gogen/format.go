@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+// 支持的输出格式
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatJUnit = "junit"
+)
+
+// reporter 输出运行过程和结果
+type reporter interface {
+	start(cmd generator.Command)
+	finish(result generator.Result)
+	// done 在运行结束后调用，report 在查找指令失败时为空
+	done(report *generator.Report, err error)
+	// plan 输出 Plan 的结果
+	plan(report *generator.Report, err error)
+}
+
+// newReporter 创建指定格式的 reporter，结构化的结果写入 stdout
+func newReporter(format, dir string) reporter {
+	switch format {
+	case formatJSON:
+		return &jsonReporter{dir: dir, enc: json.NewEncoder(os.Stdout)}
+	case formatJUnit:
+		return &junitReporter{dir: dir, w: os.Stdout}
+	default:
+		return &textReporter{dir: dir, w: os.Stderr, out: os.Stdout}
+	}
+}
+
+// jsonReporter 在命令开始和结束时各输出一行 JSON 事件，运行结束后输出汇总事件
+type jsonReporter struct {
+	dir string
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+type startEvent struct {
+	Event   string    `json:"event"`
+	Time    time.Time `json:"time"`
+	File    string    `json:"file"`
+	Line    int       `json:"line"`
+	Command string    `json:"command"`
+	Pattern string    `json:"pattern,omitempty"`
+}
+
+type finishEvent struct {
+	startEvent
+	Status     generator.Status `json:"status"`
+	Reason     generator.Reason `json:"reason,omitempty"`
+	DurationMs int64            `json:"duration_ms"`
+	ExitCode   int              `json:"exit_code"`
+	Stdout     string           `json:"stdout,omitempty"`
+	Stderr     string           `json:"stderr,omitempty"`
+	Outputs    []string         `json:"outputs,omitempty"`
+	Created    []string         `json:"created,omitempty"`
+	Modified   []string         `json:"modified,omitempty"`
+	Deleted    []string         `json:"deleted,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+type summaryEvent struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Planned    int       `json:"planned,omitempty"`
+	Executed   int       `json:"executed"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	Cancelled  int       `json:"cancelled"`
+	TimedOut   int       `json:"timed_out"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+func (r *jsonReporter) newStartEvent(event string, cmd generator.Command) startEvent {
+	return startEvent{
+		Event:   event,
+		Time:    time.Now(),
+		File:    rel(r.dir, cmd.GetFilePath()),
+		Line:    cmd.GetLine(),
+		Command: cmd.String(),
+		Pattern: cmd.GetPattern(),
+	}
+}
+
+func (r *jsonReporter) start(cmd generator.Command) {
+	r.encode(r.newStartEvent("start", cmd))
+}
+
+func (r *jsonReporter) finish(result generator.Result) {
+	r.encode(r.newFinishEvent("finish", result))
+}
+
+func (r *jsonReporter) newFinishEvent(name string, result generator.Result) finishEvent {
+	event := finishEvent{
+		startEvent: r.newStartEvent(name, result.Command),
+		Status:     result.Status,
+		Reason:     result.Reason,
+		DurationMs: result.Duration.Milliseconds(),
+		ExitCode:   result.ExitCode,
+		Stdout:     string(result.Stdout),
+		Stderr:     string(result.Stderr),
+		Outputs:    r.rels(result.Outputs),
+		Created:    r.rels(result.Changes.Created),
+		Modified:   r.rels(result.Changes.Modified),
+		Deleted:    r.rels(result.Changes.Deleted),
+	}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	return event
+}
+
+func (r *jsonReporter) done(report *generator.Report, err error) {
+	event := summaryEvent{Event: "summary", Time: time.Now()}
+	if report != nil {
+		event.Executed = report.Count(generator.StatusExecuted)
+		event.Skipped = report.Count(generator.StatusSkipped)
+		event.Failed = report.Count(generator.StatusFailed)
+		event.Cancelled = report.Count(generator.StatusCancelled)
+		event.TimedOut = report.Count(generator.StatusTimedOut)
+		event.Planned = report.Count(generator.StatusPlanned)
+		event.DurationMs = report.Duration.Milliseconds()
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	r.encode(event)
+}
+
+// plan 为每条指令输出一行 plan 事件，最后输出汇总事件
+func (r *jsonReporter) plan(report *generator.Report, err error) {
+	if report != nil {
+		for _, result := range report.Results {
+			r.encode(r.newFinishEvent("plan", result))
+		}
+	}
+	r.done(report, err)
+}
+
+func (r *jsonReporter) rels(paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	out := make([]string, len(paths))
+	for i, path := range paths {
+		out[i] = rel(r.dir, path)
+	}
+	return out
+}
+
+func (r *jsonReporter) encode(event any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(event); err != nil {
+		log.Printf("write event failed: %v", err)
+	}
+}
+
+// junitReporter 在运行结束后输出 JUnit XML，每条指令为一个测试用例，
+// 失败和需要执行的指令为 failure，取消的指令为 error，缓存命中的指令为 skipped
+type junitReporter struct {
+	dir string
+	w   io.Writer
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func (r *junitReporter) start(cmd generator.Command)    {}
+func (r *junitReporter) finish(result generator.Result) {}
+
+func (r *junitReporter) done(report *generator.Report, err error) {
+	suite := junitTestSuite{Name: "gogen"}
+	if report == nil {
+		// 查找指令失败时没有结果，输出一个错误用例
+		suite.Cases = []junitTestCase{{
+			Name:      "find directives",
+			Classname: "gogen",
+			Time:      seconds(0),
+			Error:     &junitFailure{Message: err.Error(), Type: "error"},
+		}}
+		suite.Tests, suite.Errors, suite.Time = 1, 1, seconds(0)
+	} else {
+		suite.Time = seconds(report.Duration)
+		for _, result := range report.Results {
+			suite.Cases = append(suite.Cases, r.testCase(result, &suite))
+		}
+		suite.Tests = len(suite.Cases)
+	}
+
+	out, xmlErr := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if xmlErr != nil {
+		log.Printf("marshal junit report failed: %v", xmlErr)
+		return
+	}
+	fmt.Fprintf(r.w, "%s%s\n", xml.Header, out)
+}
+
+func (r *junitReporter) plan(report *generator.Report, err error) {
+	r.done(report, err)
+}
+
+func (r *junitReporter) testCase(result generator.Result, suite *junitTestSuite) junitTestCase {
+	cmd := result.Command
+	tc := junitTestCase{
+		Name:      fmt.Sprintf("line %d: %s", cmd.GetLine(), cmd),
+		Classname: rel(r.dir, cmd.GetFilePath()),
+		Time:      seconds(result.Duration),
+		SystemOut: string(result.Stdout),
+		SystemErr: string(result.Stderr),
+	}
+
+	switch result.Status {
+	case generator.StatusFailed:
+		suite.Failures++
+		tc.Failure = &junitFailure{Message: message(result.Err), Type: "failed", Text: result.Err.Error()}
+	case generator.StatusTimedOut:
+		suite.Failures++
+		tc.Failure = &junitFailure{Message: message(result.Err), Type: "timed out", Text: result.Err.Error()}
+	case generator.StatusCancelled:
+		suite.Errors++
+		tc.Error = &junitFailure{Message: message(result.Err), Type: "cancelled", Text: result.Err.Error()}
+	case generator.StatusPlanned:
+		suite.Failures++
+		tc.Failure = &junitFailure{Message: "would run: " + string(result.Reason), Type: "planned"}
+	case generator.StatusSkipped:
+		suite.Skipped++
+		tc.Skipped = &junitSkipped{Message: string(result.Reason)}
+	}
+	return tc
+}
+
+// message 返回错误的简短描述，命令执行失败时不包含命令输出
+func message(err error) string {
+	var execErr *generator.ExecError
+	if errors.As(err, &execErr) {
+		return execErr.Err.Error()
+	}
+	return err.Error()
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/command"
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+func testReport(dir string) (*generator.Report, error) {
+	path := filepath.Join(dir, "api", "a.go")
+	report := &generator.Report{
+		Duration: 1500 * time.Millisecond,
+		Results: []generator.Result{
+			{Command: command.NewCommand(path, 3, "mockgen -source=a.go"), Status: generator.StatusExecuted, Reason: generator.ReasonNew, Stdout: []byte("ok\n")},
+			{Command: command.NewCommand(path, 4, "stringer -type=Kind"), Status: generator.StatusSkipped, Reason: generator.ReasonCached},
+			{
+				Command:  command.NewCommand(path, 5, "protoc a.proto"),
+				Status:   generator.StatusFailed,
+				ExitCode: 1,
+				Err:      &generator.ExecError{Output: []byte("a.proto: not found"), Err: errors.New("exit status 1")},
+			},
+		},
+	}
+	return report, report.Err()
+}
+
+func TestJUnitReporter(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	r := &junitReporter{dir: dir, w: &buf}
+	r.done(testReport(dir))
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatalf("invalid xml: %v\n%s", err, buf.String())
+	}
+	suite := suites.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Skipped != 1 || suite.Time != "1.500" {
+		t.Errorf("unexpected suite %+v", suite)
+	}
+	failed := suite.Cases[2]
+	if failed.Classname != filepath.Join("api", "a.go") || failed.Name != "line 5: protoc a.proto" {
+		t.Errorf("unexpected test case %+v", failed)
+	}
+	if failed.Failure == nil || failed.Failure.Message != "exit status 1" || !strings.Contains(failed.Failure.Text, "a.proto: not found") {
+		t.Errorf("unexpected failure %+v", failed.Failure)
+	}
+}
+
+func TestJSONReporter(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	r := &jsonReporter{dir: dir, enc: json.NewEncoder(&buf)}
+
+	report, err := testReport(dir)
+	for _, result := range report.Results {
+		r.start(result.Command)
+		r.finish(result)
+	}
+	r.done(report, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("expected 7 events, got %d:\n%s", len(lines), buf.String())
+	}
+
+	var finish finishEvent
+	if err := json.Unmarshal([]byte(lines[5]), &finish); err != nil {
+		t.Fatal(err)
+	}
+	if finish.Event != "finish" || finish.Status != generator.StatusFailed || finish.ExitCode != 1 || finish.File != filepath.Join("api", "a.go") {
+		t.Errorf("unexpected finish event %+v", finish)
+	}
+
+	var summary summaryEvent
+	if err := json.Unmarshal([]byte(lines[6]), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Executed != 1 || summary.Skipped != 1 || summary.Failed != 1 || summary.Error == "" {
+		t.Errorf("unexpected summary event %+v", summary)
+	}
+}
@@ -2,137 +2,263 @@ package main
 
 import (
 	"context"
-	"flag"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
-	"runtime"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/llamazing-cn/go-generate-manager/pkg/cache"
 	"github.com/llamazing-cn/go-generate-manager/pkg/command"
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
 	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+	"github.com/llamazing-cn/go-generate-manager/pkg/graph"
 	"github.com/llamazing-cn/go-generate-manager/pkg/hash"
 )
 
-const usage = `Usage: gogen [options]
-
-Options:
-  -d, --dir     <path>     directory to generate files
-  -c, --cmd     <command>  command to use for code generation
-  -o, --output  <path>     directory to output files
-  -w, --workers <number>   number of worker goroutines (default: number of CPUs)
-  -h, --help              show this help message
-
-Example:
-  gogen -d ./src -c mockgen -o ./gen
-  gogen --dir=./src --cmd=mockgen --output=./gen --workers=4
-`
-
 func main() {
-	log.Println("starting generation process")
-	start := time.Now()
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "config":
+			runConfig(args[1:])
+			return
+		case "cache":
+			runCache(args[1:])
+			return
+		case "check":
+			if cfg, _ := loadConfig("gogen check", args[1:]); cfg != nil {
+				runCheck(cfg)
+			}
+			return
+		case "watch":
+			if cfg, _ := loadConfig("gogen watch", args[1:]); cfg != nil {
+				runWatch(cfg)
+			}
+			return
+		case "plan":
+			if cfg, opts := loadConfig("gogen plan", args[1:]); cfg != nil {
+				runPlan(cfg, opts)
+			}
+			return
+		}
+	}
 
-	cfg := parseFlags()
+	start := time.Now()
+	cfg, opts := loadConfig("gogen", args)
 	if cfg == nil {
 		return
 	}
-
-	if cfg.dir == "..." {
-		var err error
-		cfg.dir, err = os.Getwd()
-		if err != nil {
-			log.Fatalf("get working directory failed: %v", err)
-		}
+	if opts.dryRun {
+		runPlan(cfg, opts)
+		return
 	}
+	log.Println("starting generation process")
 
-	if cfg.output == "" {
-		cfg.output = cfg.dir
-	}
+	ctx, stop := interruptContext(context.Background())
+	defer stop()
+	ctx, cancel := withTimeout(ctx, cfg)
+	defer cancel()
 
-	cacheFile := filepath.Join(cfg.output, cfg.cmd+".sum")
-	cache := cache.NewFileCache(cacheFile)
-	if err := cache.Load(); err != nil {
+	cache, err := loadCache(cfg, false)
+	if err != nil {
 		log.Fatalf("load cache failed: %v", err)
 	}
-	defer func() {
-		if err := cache.Save(); err != nil {
-			log.Printf("save cache failed: %v", err)
-		}
-	}()
 
-	gen := generator.New(generator.Options{
-		Hasher:  hash.NewContentHasher(),
-		Cache:   cache,
-		Finder:  command.NewFinder(cfg.cmd),
-		Workers: cfg.workers,
-	})
+	rep := newReporter(cfg.Format, cfg.Dir)
+	genOpts := generatorOptions(cfg, cache)
+	genOpts.OnStart = rep.start
+	genOpts.OnResult = rep.finish
+	genOpts.Prune = prunable(opts)
+	// 加载包依赖图失败时（例如目录不在 Go 模块中）只按目录顺序执行
+	if g, err := graph.Load(ctx, cfg.Dir); err != nil {
+		log.Printf("load package graph failed, running without dependency order: %v", err)
+	} else {
+		genOpts.Graph = g
+	}
+	gen := generator.New(genOpts)
 
-	ctx := context.Background()
-	if err := gen.Generate(ctx, cfg.dir); err != nil {
+	report, err := gen.Run(ctx, cfg.Dir)
+	rep.done(report, err)
+	// 失败或被中断时同样保存已完成的指令
+	if err := cache.Save(); err != nil {
+		log.Printf("save cache failed: %v", err)
+	}
+	if report != nil && len(report.Pruned) > 0 {
+		log.Printf("pruned %d stale cache entries", len(report.Pruned))
+	}
+
+	if sig := interrupted(ctx); sig != nil {
+		if report != nil {
+			log.Printf("generation %s: %s", sig, summarize(report))
+		} else {
+			log.Printf("generation %s", sig)
+		}
+		os.Exit(sig.exitCode())
+	}
+	if err != nil {
+		var multi *generator.MultiError
+		if errors.As(err, &multi) {
+			log.Fatalf("generation failed: %d directives failed, %s", len(multi.Errors), summarize(report))
+		}
 		log.Fatalf("generation failed: %v", err)
 	}
 
 	elapsed := time.Since(start)
-	log.Printf("generation completed in %s", elapsed)
+	log.Printf("generation completed in %s: %s", elapsed, summarize(report))
 }
 
-type config struct {
-	dir     string
-	cmd     string
-	output  string
-	workers int
-	help    bool
+// runPlan 实现 gogen plan 和 --dry-run，只检查每条指令是否需要执行，不执行命令，也不修改缓存。
+// 指定 --exit-code 时有指令需要执行则以状态 1 退出
+func runPlan(cfg *config.Config, opts *options) {
+	cache, err := loadCache(cfg, true)
+	if err != nil {
+		log.Fatalf("load cache failed: %v", err)
+	}
+
+	rep := newReporter(cfg.Format, cfg.Dir)
+	report, err := generator.New(generatorOptions(cfg, cache)).Plan(context.Background(), cfg.Dir)
+	rep.plan(report, err)
+	if err != nil {
+		var multi *generator.MultiError
+		if errors.As(err, &multi) {
+			log.Fatalf("plan failed: %d directives failed", len(multi.Errors))
+		}
+		log.Fatalf("plan failed: %v", err)
+	}
+
+	if opts.exitCode && report.Count(generator.StatusPlanned) > 0 {
+		os.Exit(1)
+	}
 }
 
-func parseFlags() *config {
-	cfg := &config{}
+// generatorOptions 返回运行和检查共用的生成器配置
+func generatorOptions(cfg *config.Config, cache generator.Cache) generator.Options {
+	return generator.Options{
+		Hasher:  hash.NewSourceHasher(),
+		Tools:   hash.NewContentHasher(),
+		Cache:   cache,
+		Finder:  command.NewFinderWithOptions(finderOptions(cfg)),
+		Workers: cfg.Workers,
+		Limits:  limits(cfg),
+	}
+}
 
-	flag.StringVar(&cfg.dir, "d", "...", "directory to generate files (use ... for current directory)")
-	flag.StringVar(&cfg.cmd, "c", "", "command to use for code generation")
-	flag.StringVar(&cfg.output, "o", "", "directory to output files (default: same as source)")
-	workers := runtime.NumCPU() * 2
-	if workers > 8 {
-		workers = 8
+// runConfig 实现 gogen config 子命令
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		log.Print("Usage: gogen config print [options]")
+		os.Exit(2)
 	}
-	flag.IntVar(&cfg.workers, "w", workers, "number of worker goroutines")
-	flag.BoolVar(&cfg.help, "h", false, "show help message")
 
-	flag.StringVar(&cfg.dir, "dir", "...", "directory to generate files (use ... for current directory)")
-	flag.StringVar(&cfg.cmd, "cmd", "", "command to use for code generation")
-	flag.StringVar(&cfg.output, "output", "", "directory to output files (default: same as source)")
-	flag.IntVar(&cfg.workers, "workers", runtime.NumCPU()*4, "number of worker goroutines")
-	flag.BoolVar(&cfg.help, "help", false, "show help message")
+	cfg, _ := loadConfig("gogen config print", args[1:])
+	if cfg == nil {
+		return
+	}
 
-	flag.Usage = func() {
-		log.Print(usage)
+	out, err := cfg.Marshal()
+	if err != nil {
+		log.Fatalf("marshal config failed: %v", err)
+	}
+	if cfg.Path != "" {
+		fmt.Printf("# config: %s\n", cfg.Path)
 	}
+	os.Stdout.Write(out)
+}
 
-	flag.Parse()
+// cacheFile 返回缓存文件，未配置时为输出目录下的 gogen.sum，与选择的生成工具无关
+func cacheFile(cfg *config.Config) string {
+	if cfg.Cache != "" {
+		return cfg.Cache
+	}
+	return filepath.Join(cacheDir(cfg), "gogen.sum")
+}
 
-	if cfg.help {
-		flag.Usage()
-		return nil
+func cacheDir(cfg *config.Config) string {
+	if cfg.Output != "" {
+		return cfg.Output
 	}
+	return cfg.Dir
+}
 
-	if !cfg.validate() {
-		flag.Usage()
+// legacyCacheFiles 返回早期版本按生成工具保存在输出目录下的 {name}.sum，gogen.sum 不存在时从中导入记录
+func legacyCacheFiles(cfg *config.Config) []string {
+	if cfg.Cache != "" {
 		return nil
 	}
+	var files []string
+	for _, g := range cfg.Generators {
+		if g.Name != allGenerators {
+			files = append(files, filepath.Join(cacheDir(cfg), g.Name+".sum"))
+		}
+	}
+	return files
+}
+
+// newCache 返回缓存，缓存文件中的路径相对于 --dir 所在的模块根目录保存
+func newCache(cfg *config.Config, readOnly bool) *cache.FileCache {
+	return cache.NewFileCacheWithOptions(cacheFile(cfg), cache.Options{
+		Root:     moduleRoot(cfg.Dir),
+		ReadOnly: readOnly,
+		Import:   legacyCacheFiles(cfg),
+	})
+}
+
+// loadCache 创建并加载缓存。readOnly 为 true 时只读取缓存文件和日志，不修改工作区
+func loadCache(cfg *config.Config, readOnly bool) (*cache.FileCache, error) {
+	c := newCache(cfg, readOnly)
+	return c, c.Load()
+}
 
-	return cfg
+// prunable 判断运行是否覆盖缓存文件中的所有指令，此时才能删除没有遇到的指令的记录：
+// 所有生成工具共用缓存文件，只有运行所有指令或配置中的所有生成工具时才能删除
+func prunable(opts *options) bool {
+	return len(opts.cmds) == 0 || slices.Contains(opts.cmds, allGenerators)
 }
 
-func (c *config) validate() bool {
-	if c.cmd == "" {
-		log.Println("Error: required flag -cmd must be set")
-		return false
+// limits 返回各生成工具的并发限制
+func limits(cfg *config.Config) map[string]int {
+	limits := make(map[string]int)
+	for _, g := range cfg.Generators {
+		if g.Workers > 0 {
+			limits[g.Name] = g.Workers
+		}
 	}
+	return limits
+}
 
-	if c.workers < 1 {
-		log.Printf("Warning: invalid worker count %d, using 1 instead", c.workers)
-		c.workers = 1
+func finderOptions(cfg *config.Config) command.Options {
+	opts := command.Options{
+		OutputDir:   cfg.Output,
+		OutputFlags: cfg.OutputFlags,
+		Include:     cfg.Include,
+		Exclude:     cfg.Exclude,
+		Env:         environ(cfg.Env),
+		Timeout:     cfg.CommandTimeout,
 	}
+	for _, g := range cfg.Generators {
+		if g.Name == allGenerators {
+			opts.MatchAll = true
+			continue
+		}
+		opts.Patterns = append(opts.Patterns, command.Pattern{
+			Prefix:  g.Name,
+			Env:     environ(g.Env),
+			Timeout: g.Timeout,
+		})
+	}
+	return opts
+}
 
-	return true
+// environ 把环境变量配置转换为按名称排序的 "KEY=VALUE" 列表
+func environ(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
 }
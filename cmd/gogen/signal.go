@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+)
+
+// interruptError 为收到 SIGINT 或 SIGTERM 时上下文的取消原因
+type interruptError struct {
+	sig os.Signal
+}
+
+func (e *interruptError) Error() string {
+	return fmt.Sprintf("interrupted by %s", e.sig)
+}
+
+// exitCode 返回按惯例被信号中断时的退出状态 128+信号值
+func (e *interruptError) exitCode() int {
+	if s, ok := e.sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// interruptContext 返回收到 SIGINT 或 SIGTERM 时取消的上下文。第一次收到信号时取消上下文，
+// 正在执行的指令收到 SIGTERM，等待其结束后保存缓存；再次收到信号时立即退出
+func interruptContext(parent context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig, ok := <-signals
+		if !ok {
+			return
+		}
+		log.Printf("received %s, stopping running generators (repeat to exit immediately)", sig)
+		err := &interruptError{sig: sig}
+		cancel(err)
+
+		if _, ok := <-signals; ok {
+			log.Printf("received %s again, exiting", sig)
+			os.Exit(err.exitCode())
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(signals)
+		cancel(nil)
+	}
+}
+
+// interrupted 返回上下文因收到信号而取消的原因，没有收到信号时返回 nil
+func interrupted(ctx context.Context) *interruptError {
+	var err *interruptError
+	if errors.As(context.Cause(ctx), &err) {
+		return err
+	}
+	return nil
+}
+
+// withTimeout 在配置了 timeout 时为一次运行加上超时时间，未配置时只返回可取消的 ctx
+func withTimeout(ctx context.Context, cfg *config.Config) (context.Context, context.CancelFunc) {
+	if cfg.Timeout > 0 {
+		return context.WithTimeout(ctx, cfg.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+)
+
+func TestWithTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background(), &config.Config{Timeout: time.Hour})
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Hour {
+		t.Errorf("expected deadline within the configured timeout, got %v (%v)", deadline, ok)
+	}
+
+	ctx, cancel = withTimeout(context.Background(), &config.Config{})
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("expected no deadline without a timeout")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+// textReporter 以日志的形式输出各命令的执行结果和文件变化，
+// 运行结束后按源文件分组列出失败的指令
+type textReporter struct {
+	dir string
+	w   io.Writer
+	// out 用于输出 plan 的结果
+	out io.Writer
+
+	mu sync.Mutex
+}
+
+func (r *textReporter) start(cmd generator.Command) {}
+
+func (r *textReporter) finish(result generator.Result) {
+	if result.Status != generator.StatusExecuted {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cmd, changes := result.Command, result.Changes
+	log.Printf("%s: %s (%s, %s)", generator.CommandLocation(cmd), cmd, result.Reason, result.Duration.Round(time.Millisecond))
+	for _, c := range []struct {
+		action string
+		paths  []string
+	}{
+		{"created", changes.Created},
+		{"modified", changes.Modified},
+		{"deleted", changes.Deleted},
+	} {
+		for _, path := range c.paths {
+			log.Printf("%s: %s %s", generator.CommandLocation(cmd), c.action, rel(r.dir, path))
+		}
+	}
+}
+
+func (r *textReporter) done(report *generator.Report, err error) {
+	var multi *generator.MultiError
+	if errors.As(err, &multi) {
+		fmt.Fprint(r.w, failures(r.dir, multi))
+	}
+}
+
+// plan 逐行输出每条指令是否会执行及其原因
+func (r *textReporter) plan(report *generator.Report, err error) {
+	if report != nil {
+		tw := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+		for _, result := range report.Results {
+			action, reason := "run", string(result.Reason)
+			switch result.Status {
+			case generator.StatusSkipped:
+				action = "skip"
+			case generator.StatusFailed, generator.StatusTimedOut, generator.StatusCancelled:
+				action, reason = "error", string(result.Status)
+			}
+			fmt.Fprintf(tw, "%s\t%s:%d\t%s\t%s\n",
+				action, rel(r.dir, result.Command.GetFilePath()), result.Command.GetLine(), reason, result.Command)
+		}
+		tw.Flush()
+		fmt.Fprintf(r.out, "%d of %d directives would run\n", report.Count(generator.StatusPlanned), len(report.Results))
+	}
+	r.done(report, err)
+}
+
+// rel 返回相对于 dir 的路径，无法计算时返回原路径
+func rel(dir, path string) string {
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// summarize 汇总各状态的指令数和文件变化，有失败、超时或取消的指令时一并列出
+func summarize(report *generator.Report) string {
+	var created, modified, deleted int
+	for _, result := range report.Results {
+		created += len(result.Changes.Created)
+		modified += len(result.Changes.Modified)
+		deleted += len(result.Changes.Deleted)
+	}
+	summary := fmt.Sprintf("%d commands executed, %d cached, %d files created, %d modified, %d deleted",
+		report.Count(generator.StatusExecuted), report.Count(generator.StatusSkipped), created, modified, deleted)
+	for _, status := range []generator.Status{generator.StatusFailed, generator.StatusTimedOut, generator.StatusCancelled} {
+		if n := report.Count(status); n > 0 {
+			summary += fmt.Sprintf(", %d %s", n, status)
+		}
+	}
+	return summary
+}
+
+// failures 按源文件分组列出所有失败的指令及其输出
+func failures(dir string, multi *generator.MultiError) string {
+	var (
+		b    strings.Builder
+		file string
+	)
+	// 运行被取消或超时时尚未开始的指令没有输出，只计入汇总，不逐条列出；
+	// 执行中超时的指令包含 generator.ErrTimedOut，连同结束前的输出一起列出
+	var errs []*generator.CommandError
+	for _, e := range multi.Errors {
+		notStarted := errors.Is(e.Err, context.Canceled) || errors.Is(e.Err, context.DeadlineExceeded)
+		if !notStarted || errors.Is(e.Err, generator.ErrTimedOut) {
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) == 0 {
+		return ""
+	}
+
+	fmt.Fprintf(&b, "%d directives failed:\n", len(errs))
+	for _, e := range errs {
+		if e.File != file {
+			file = e.File
+			fmt.Fprintf(&b, "%s\n", rel(dir, file))
+		}
+		fmt.Fprintf(&b, "  line %d: %s\n", e.Line, e.Command)
+
+		cause := e.Err
+		var execErr *generator.ExecError
+		if errors.As(cause, &execErr) {
+			cause = execErr.Err
+		}
+		fmt.Fprintf(&b, "    %v\n", cause)
+		if out := strings.TrimSpace(e.Output); out != "" {
+			for _, line := range strings.Split(out, "\n") {
+				fmt.Fprintf(&b, "    | %s\n", line)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/command"
+	"github.com/llamazing-cn/go-generate-manager/pkg/config"
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+	"github.com/llamazing-cn/go-generate-manager/pkg/graph"
+	"github.com/llamazing-cn/go-generate-manager/pkg/watch"
+)
+
+// debounce 为合并连续文件变化的等待时间
+const debounce = 200 * time.Millisecond
+
+// runWatch 实现 gogen watch 子命令：先运行一次所有指令，之后监听 --dir 下的文件变化，
+// 在变化停止 debounce 后只重新检查受影响的指令。指令、缓存和输出文件保存在内存中，
+// 每次运行后保存缓存
+func runWatch(cfg *config.Config) {
+	ctx, stop := interruptContext(context.Background())
+	defer stop()
+
+	sum, err := loadCache(cfg, false)
+	if err != nil {
+		log.Fatalf("load cache failed: %v", err)
+	}
+
+	// 先开始监听，避免遗漏第一次运行期间的变化
+	w, err := watch.New(cfg.Dir, func(dir string) bool {
+		return strings.HasPrefix(filepath.Base(dir), ".")
+	})
+	if err != nil {
+		log.Fatalf("watch %s failed: %v", cfg.Dir, err)
+	}
+	defer w.Close()
+
+	pkgs := &watchGraph{}
+	loadGraph := func() {
+		g, err := graph.Load(ctx, cfg.Dir)
+		if err != nil {
+			log.Printf("load package graph failed, running without dependency order: %v", err)
+			pkgs.set(nil)
+			return
+		}
+		pkgs.set(g)
+	}
+	loadGraph()
+
+	finder := &watchFinder{
+		finder:  command.NewFinderWithOptions(finderOptions(cfg)),
+		root:    cfg.Dir,
+		graph:   pkgs,
+		outputs: make(map[string][]string),
+	}
+	if err := finder.scan(); err != nil {
+		log.Fatalf("find commands failed: %v", err)
+	}
+
+	rep := newReporter(cfg.Format, cfg.Dir)
+	opts := generatorOptions(cfg, sum)
+	opts.Finder = finder
+	opts.OnStart = rep.start
+	opts.OnResult = rep.finish
+	opts.Graph = pkgs
+	opts.RerunDependents = true
+	gen := generator.New(opts)
+
+	// 超时时间限制每次运行，而不是整个监听过程
+	run := func() {
+		runCtx, cancel := withTimeout(ctx, cfg)
+		defer cancel()
+		report, err := gen.Run(runCtx, cfg.Dir)
+		rep.done(report, err)
+		if report == nil {
+			log.Printf("generation failed: %v", err)
+			return
+		}
+		finder.record(report)
+		if err := sum.Save(); err != nil {
+			log.Printf("save cache failed: %v", err)
+		}
+		if report.Count(generator.StatusSkipped) < len(report.Results) {
+			log.Printf("generation finished in %s: %s", report.Duration.Round(time.Millisecond), summarize(report))
+		}
+	}
+
+	run()
+	log.Printf("watching %s for changes", cfg.Dir)
+
+	var (
+		pending = make(map[string]bool)
+		rescan  bool
+		timer   = time.NewTimer(debounce)
+	)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("stopping watch")
+			return
+
+		case path, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			pending[path] = true
+			timer.Reset(debounce)
+
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			if !errors.Is(err, watch.ErrOverflow) {
+				log.Printf("watch failed: %v", err)
+				return
+			}
+			rescan = true
+			timer.Reset(debounce)
+
+		case <-timer.C:
+			// go.mod 或导入变化后包依赖图可能变化，在确定受影响的指令之前重新加载
+			if rescan || finder.importsChanged(pending) {
+				loadGraph()
+			}
+			if rescan {
+				err = finder.scan()
+			} else {
+				err = finder.update(pending)
+			}
+			pending, rescan = make(map[string]bool), false
+			if err != nil {
+				log.Printf("find commands failed: %v", err)
+				continue
+			}
+			if finder.only == nil || len(finder.only) > 0 {
+				run()
+			}
+		}
+	}
+}
+
+// watchGraph 包装包依赖图，go.mod 或导入变化后重新加载时替换，加载失败时没有依赖
+type watchGraph struct {
+	mu sync.RWMutex
+	g  generator.PackageGraph
+}
+
+func (w *watchGraph) set(g generator.PackageGraph) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.g = g
+}
+
+func (w *watchGraph) Deps(dir string) []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.g == nil {
+		return nil
+	}
+	return w.g.Deps(dir)
+}
+
+// watchFinder 在内存中保存每个文件中的指令，文件变化后只重新解析变化的文件，
+// 并只返回受变化影响的指令及依赖它们的指令
+type watchFinder struct {
+	finder *command.CommandFinder
+	root   string
+	graph  generator.PackageGraph
+
+	// files 记录每个文件中的指令
+	files map[string][]generator.Command
+	// imports 记录每个 Go 文件导入的包，用于判断是否需要重新加载包依赖图
+	imports map[string]string
+	// outputs 记录每条指令最近一次记录的输出文件
+	outputs map[string][]string
+	// only 为本次运行需要检查的指令，为空时检查所有指令
+	only map[string]bool
+}
+
+func (f *watchFinder) Find(dir string) ([]generator.Command, error) {
+	paths := make([]string, 0, len(f.files))
+	for path := range f.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var commands []generator.Command
+	for _, path := range paths {
+		for _, cmd := range f.files[path] {
+			if f.only == nil || f.only[generator.CommandKey(cmd)] {
+				commands = append(commands, cmd)
+			}
+		}
+	}
+	return commands, nil
+}
+
+// scan 重新扫描整个目录树，下次运行检查所有指令
+func (f *watchFinder) scan() error {
+	commands, err := f.finder.Find(f.root)
+	if err != nil {
+		return err
+	}
+	f.files = make(map[string][]generator.Command)
+	for _, cmd := range commands {
+		f.files[cmd.GetFilePath()] = append(f.files[cmd.GetFilePath()], cmd)
+	}
+	f.only = nil
+
+	f.imports = make(map[string]string)
+	return filepath.WalkDir(f.root, func(path string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case d.IsDir() && path != f.root && !packageDir(d.Name()):
+			return filepath.SkipDir
+		case !d.IsDir() && strings.HasSuffix(path, ".go"):
+			f.imports[path], _ = importsOf(path)
+		}
+		return nil
+	})
+}
+
+// importsChanged 判断变化的文件是否可能改变包依赖图：go.mod 或 go.work 变化，
+// 或 Go 文件被新建、删除或修改了导入。同时更新记录的导入
+func (f *watchFinder) importsChanged(paths map[string]bool) bool {
+	changed := false
+	for path := range paths {
+		switch name := filepath.Base(path); {
+		case name == "go.mod" || name == "go.work":
+			changed = true
+		case strings.HasSuffix(name, ".go") && inPackage(f.root, path):
+			imports, err := importsOf(path)
+			old, known := f.imports[path]
+			switch {
+			case os.IsNotExist(err):
+				delete(f.imports, path)
+				changed = changed || known
+			case err != nil:
+				// 编辑中的文件可能无法解析，等到下次保存
+			case !known || imports != old:
+				f.imports[path] = imports
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// importsOf 返回 Go 文件导入的包路径，按字典序以换行连接
+func importsOf(path string) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+	if err != nil {
+		if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+			return "", statErr
+		}
+		return "", err
+	}
+	imports := make([]string, len(file.Imports))
+	for i, spec := range file.Imports {
+		imports[i] = spec.Path.Value
+	}
+	sort.Strings(imports)
+	return strings.Join(imports, "\n"), nil
+}
+
+// packageDir 判断 go list ./... 是否会进入该目录：跳过隐藏目录、以 _ 开头的目录、testdata 和 vendor
+func packageDir(name string) bool {
+	return !strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "_") && name != "testdata" && name != "vendor"
+}
+
+// inPackage 判断 root 下的文件是否位于 go list ./... 会加载的目录中
+func inPackage(root, path string) bool {
+	dir, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || dir == ".." || strings.HasPrefix(dir, ".."+string(filepath.Separator)) {
+		return false
+	}
+	for _, name := range strings.Split(dir, string(filepath.Separator)) {
+		if name != "." && !packageDir(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// update 重新解析变化的文件，下次运行只检查变化的文件中的指令和输出文件发生变化的指令
+func (f *watchFinder) update(paths map[string]bool) error {
+	owners := make(map[string][]string)
+	for key, outputs := range f.outputs {
+		for _, path := range outputs {
+			owners[path] = append(owners[path], key)
+		}
+	}
+
+	f.only = make(map[string]bool)
+	for path := range paths {
+		commands, err := f.finder.FindFile(f.root, path)
+		if err != nil {
+			return err
+		}
+		if len(commands) == 0 {
+			delete(f.files, path)
+		} else {
+			f.files[path] = commands
+		}
+		for _, cmd := range commands {
+			f.only[generator.CommandKey(cmd)] = true
+		}
+		for _, key := range owners[path] {
+			f.only[key] = true
+		}
+	}
+
+	// 依赖受影响指令的指令同样需要重新检查，依赖的指令重新生成文件后它们会重新执行
+	selected := f.only
+	f.only = nil
+	commands, err := f.Find(f.root)
+	if err != nil {
+		return err
+	}
+	only, err := generator.Dependents(commands, f.graph, selected)
+	if err != nil {
+		f.only = selected
+		return err
+	}
+	f.only = only
+	return nil
+}
+
+// record 记录本次运行中各指令的输出文件
+func (f *watchFinder) record(report *generator.Report) {
+	for _, result := range report.Results {
+		if result.Status == generator.StatusExecuted || result.Status == generator.StatusSkipped {
+			f.outputs[generator.CommandKey(result.Command)] = result.Outputs
+		}
+	}
+}
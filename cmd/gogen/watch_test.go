@@ -0,0 +1,170 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/command"
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+func TestWatchFinder(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	output := filepath.Join(dir, "mock_b.go")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a, "package x\n//go:generate echo a\n")
+	write(b, "package x\n//go:generate echo b\n")
+
+	f := &watchFinder{
+		finder:  command.NewFinderWithOptions(command.Options{MatchAll: true}),
+		root:    dir,
+		outputs: make(map[string][]string),
+	}
+	if err := f.scan(); err != nil {
+		t.Fatal(err)
+	}
+	keys := func() []string {
+		t.Helper()
+		commands, err := f.Find(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, cmd := range commands {
+			keys = append(keys, filepath.Base(cmd.GetFilePath())+":"+cmd.String())
+		}
+		return keys
+	}
+	if got := keys(); len(got) != 2 {
+		t.Fatalf("expected all directives after scan, got %q", got)
+	}
+
+	// 记录 b.go 的输出文件，输出文件被修改时重新检查 b.go 的指令
+	commands, _ := f.Find(dir)
+	f.record(&generator.Report{Results: []generator.Result{
+		{Command: commands[1], Status: generator.StatusExecuted, Outputs: []string{output}},
+	}})
+	if err := f.update(map[string]bool{output: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(); len(got) != 1 || got[0] != "b.go:echo b" {
+		t.Errorf("expected only b.go directive, got %q", got)
+	}
+
+	// 新增和删除指令
+	write(a, "package x\n//go:generate echo a\n//go:generate echo a2\n")
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.update(map[string]bool{a: true, b: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := keys(); len(got) != 2 || got[0] != "a.go:echo a" || got[1] != "a.go:echo a2" {
+		t.Errorf("expected directives of a.go, got %q", got)
+	}
+	if _, ok := f.files[b]; ok {
+		t.Error("expected directives of removed file to be dropped")
+	}
+}
+
+func TestWatchFinderDependents(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	enum, api, other := filepath.Join(dir, "enum", "a.go"), filepath.Join(dir, "api", "a.go"), filepath.Join(dir, "other", "a.go")
+	write(enum, "package enum\n//gogen:id enums\n//go:generate echo enum\n")
+	write(api, "package api\n//go:generate echo api\n")
+	write(other, "package other\n//gogen:after enums\n//go:generate echo other\n")
+
+	f := &watchFinder{
+		finder:  command.NewFinderWithOptions(command.Options{MatchAll: true}),
+		root:    dir,
+		graph:   mockGraph{filepath.Dir(api): {filepath.Dir(enum)}},
+		outputs: make(map[string][]string),
+	}
+	if err := f.scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	// enum 变化后，通过包依赖图依赖它的 api 和通过注解依赖它的 other 同样重新检查
+	if err := f.update(map[string]bool{enum: true}); err != nil {
+		t.Fatal(err)
+	}
+	commands, _ := f.Find(dir)
+	if len(commands) != 3 {
+		t.Errorf("expected the changed directive and its dependents, got %v", commands)
+	}
+
+	// api 没有被依赖，只检查它自己
+	if err := f.update(map[string]bool{api: true}); err != nil {
+		t.Fatal(err)
+	}
+	if commands, _ := f.Find(dir); len(commands) != 1 || commands[0].String() != "echo api" {
+		t.Errorf("expected only the api directive, got %v", commands)
+	}
+}
+
+// mockGraph 以目录为键记录依赖的目录
+type mockGraph map[string][]string
+
+func (g mockGraph) Deps(dir string) []string { return g[dir] }
+
+func TestWatchFinderImportsChanged(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(a, "package x\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n")
+
+	f := &watchFinder{
+		finder:  command.NewFinderWithOptions(command.Options{MatchAll: true}),
+		root:    dir,
+		outputs: make(map[string][]string),
+	}
+	if err := f.scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name string
+		edit func()
+		path string
+		want bool
+	}{
+		{"body changed", func() { write(a, "package x\n\nimport \"fmt\"\n\nvar _ = fmt.Sprintln\n") }, a, false},
+		{"import added", func() { write(a, "package x\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n") }, a, true},
+		{"syntax error", func() { write(a, "package x\n\nimport (\n") }, a, false},
+		{"go.mod changed", func() { write(filepath.Join(dir, "go.mod"), "module x\n") }, filepath.Join(dir, "go.mod"), true},
+		{"file added", func() { write(filepath.Join(dir, "b.go"), "package x\n") }, filepath.Join(dir, "b.go"), true},
+		{"testdata ignored", func() {
+			os.MkdirAll(filepath.Join(dir, "testdata"), 0755)
+			write(filepath.Join(dir, "testdata", "c.go"), "package c\n")
+		}, filepath.Join(dir, "testdata", "c.go"), false},
+		{"file removed", func() { os.Remove(filepath.Join(dir, "b.go")) }, filepath.Join(dir, "b.go"), true},
+	}
+	for _, step := range steps {
+		step.edit()
+		if got := f.importsChanged(map[string]bool{step.path: true}); got != step.want {
+			t.Errorf("%s: got %v, want %v", step.name, got, step.want)
+		}
+	}
+}
@@ -2,4 +2,8 @@ module github.com/llamazing-cn/go-generate-manager
 
 go 1.23.0
 
-require github.com/cespare/xxhash/v2 v2.3.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/cespare/xxhash/v2 v2.3.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -1,80 +1,285 @@
 package cache
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
 )
 
+// Options 配置文件缓存
+type Options struct {
+	// Root 为项目根目录，缓存文件中的路径相对于该目录保存，
+	// 移动或在其他位置克隆仓库后缓存仍然有效。为空时使用缓存文件所在目录
+	Root string
+
+	// ReadOnly 为 true 时 Load 只在内存中重放日志，不写回缓存文件也不删除日志；
+	// Set 不写日志，Save 返回 ErrReadOnly。用于 plan、check 等不应修改工作区的命令
+	ReadOnly bool
+
+	// Import 为缓存文件不存在时导入记录的其他缓存文件，用于迁移早期版本按生成工具保存的 {name}.sum。
+	// 导入的记录在 Save 时写入缓存文件，原文件保持不变
+	Import []string
+}
+
+// ErrReadOnly 表示以只读方式打开的缓存不能保存
+var ErrReadOnly = errors.New("cache is read-only")
+
+// FileCache 把缓存保存在文本文件中。多个进程可以同时使用同一个缓存文件：
+// 保存时重新读取文件，只把本进程修改或删除的记录合并进去，不会覆盖其他进程保存的记录。
+// 每次 Set 同时追加到日志文件，进程异常退出后下次 Load 时恢复
 type FileCache struct {
-	path   string
-	hashes map[string]string
-	mu     sync.RWMutex
+	path    string
+	root    string
+	entries map[string]generator.Entry
+	// dirty 记录 Load 之后修改或删除的键
+	dirty    map[string]bool
+	readOnly bool
+	imports  []string
+	mu       sync.RWMutex
 }
 
 func NewFileCache(path string) *FileCache {
+	return NewFileCacheWithOptions(path, Options{})
+}
+
+func NewFileCacheWithOptions(path string, opts Options) *FileCache {
+	root := opts.Root
+	if root == "" {
+		root = filepath.Dir(path)
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
 	return &FileCache{
-		path:   path,
-		hashes: make(map[string]string),
+		path:     path,
+		root:     root,
+		entries:  make(map[string]generator.Entry),
+		dirty:    make(map[string]bool),
+		readOnly: opts.ReadOnly,
+		imports:  opts.Import,
 	}
 }
 
+// Load 读取缓存文件并重放上次保存之后日志中的记录，有日志时把重放结果写回缓存文件并删除日志。
+// 早期版本格式的缓存文件只转换到内存中，由之后的 Save 按当前格式重写。
+// 只读的缓存持有共享锁读取，不修改任何文件
 func (c *FileCache) Load() error {
+	dir := filepath.Dir(c.path)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	unlock, err := lockDir(dir, !c.readOnly)
+	if err != nil {
+		return fmt.Errorf("lock cache directory: %w", err)
+	}
+	defer unlock()
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	content, err := os.ReadFile(c.path)
+	entries, old, replayed, err := c.read()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil
-		}
-		return fmt.Errorf("read cache file: %w", err)
+		return err
 	}
-
-	c.hashes = make(map[string]string)
-	for _, line := range strings.Split(string(content), "\n") {
-		parts := strings.Split(line, " ")
-		if len(parts) == 2 {
-			c.hashes[parts[0]] = parts[1]
-		}
+	c.entries = entries
+	c.dirty = make(map[string]bool)
+	if old == nil {
+		c.importFiles()
+	}
+	if replayed && !c.readOnly {
+		return c.compact(old)
 	}
 	return nil
 }
 
+// Save 把缓存写入临时文件并同步到磁盘后重命名为缓存文件，写入中途失败不会破坏原有文件。
+// 保存期间持有缓存目录的排他锁，先合并其他进程在 Load 之后保存或写入日志的记录，
+// 再写入本进程修改的记录。记录按路径和行号排序，内容没有变化时不重写文件
 func (c *FileCache) Save() error {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
+	if c.readOnly {
+		return ErrReadOnly
+	}
 	dir := filepath.Dir(c.path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("create cache directory: %w", err)
 	}
+	unlock, err := lockDir(dir, true)
+	if err != nil {
+		return fmt.Errorf("lock cache directory: %w", err)
+	}
+	defer unlock()
 
-	file, err := os.Create(c.path)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, old, _, err := c.read()
 	if err != nil {
-		return fmt.Errorf("create cache file: %w", err)
+		return err
+	}
+	c.entries = c.merge(entries)
+	if err := c.compact(old); err != nil {
+		return err
 	}
-	defer file.Close()
+	c.dirty = make(map[string]bool)
+	return nil
+}
 
-	for path, hash := range c.hashes {
-		if _, err := fmt.Fprintf(file, "%s %s\n", path, hash); err != nil {
-			return fmt.Errorf("write cache entry: %w", err)
+// read 读取缓存文件并重放日志，返回所有记录、缓存文件原有的内容以及是否重放了日志。
+// 调用方需要持有缓存目录的排他锁
+func (c *FileCache) read() (map[string]generator.Entry, []byte, bool, error) {
+	entries := make(map[string]generator.Entry)
+	old, err := os.ReadFile(c.path)
+	switch {
+	case err == nil:
+		entries, err = c.decode(old)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("parse cache file %s: %w", c.path, err)
 		}
+	case !os.IsNotExist(err):
+		return nil, nil, false, fmt.Errorf("read cache file: %w", err)
+	}
+
+	journal, err := os.ReadFile(c.journalPath())
+	switch {
+	case err == nil:
+		c.replay(entries, journal)
+		return entries, old, true, nil
+	case !os.IsNotExist(err):
+		return nil, nil, false, fmt.Errorf("read cache journal: %w", err)
+	}
+	return entries, old, false, nil
+}
+
+// importFiles 从 Options.Import 中的缓存文件导入记录，已有的记录优先，无法读取的文件被忽略。
+// 导入的记录标记为已修改，Save 时写入缓存文件
+func (c *FileCache) importFiles() {
+	for _, path := range c.imports {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		entries, err := c.decode(content)
+		if err != nil {
+			continue
+		}
+		for key, entry := range entries {
+			if _, exists := c.entries[key]; !exists {
+				c.entries[key] = entry
+				c.dirty[key] = true
+			}
+		}
+	}
+}
+
+// compact 把内存中的记录写入缓存文件并删除已合并的日志，调用方需要持有缓存目录的排他锁
+func (c *FileCache) compact(old []byte) error {
+	data := c.encode(c.entries)
+	if !bytes.Equal(old, data) {
+		if err := writeFile(c.path, data); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(c.journalPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove cache journal: %w", err)
 	}
 	return nil
 }
 
-func (c *FileCache) Get(path string) (string, bool) {
+// merge 在磁盘上的最新记录之上应用本进程修改和删除的记录，未修改的记录以磁盘为准
+func (c *FileCache) merge(disk map[string]generator.Entry) map[string]generator.Entry {
+	for key := range c.dirty {
+		if entry, ok := c.entries[key]; ok {
+			disk[key] = entry
+		} else {
+			delete(disk, key)
+		}
+	}
+	return disk
+}
+
+// writeFile 原子地替换文件内容：写入同目录下的临时文件，fsync 后重命名
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("create temp cache file: %w", err)
+	}
+	// 重命名成功后临时文件已不存在，删除失败可以忽略
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("chmod cache file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename cache file: %w", err)
+	}
+
+	// 同步目录，保证重命名在崩溃后仍然有效
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+func (c *FileCache) Get(key string) (generator.Entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, exists := c.entries[key]
+	return entry, exists
+}
+
+// Set 更新记录并追加到日志中，进程在保存前退出时已完成的记录不会丢失
+func (c *FileCache) Set(key string, entry generator.Entry) {
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.dirty[key] = true
+	c.mu.Unlock()
+
+	if !c.readOnly {
+		c.appendJournal(key, entry)
+	}
+}
+
+// Keys 返回按路径和行号排序的所有键
+func (c *FileCache) Keys() []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	hash, exists := c.hashes[path]
-	return hash, exists
+	return generator.SortedKeys(c.entries)
 }
 
-func (c *FileCache) Set(path, hash string) {
+func (c *FileCache) Delete(key string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.hashes[path] = hash
+	delete(c.entries, key)
+	c.dirty[key] = true
+}
+
+// Size 返回缓存文件的大小，文件不存在时返回 0
+func (c *FileCache) Size() (int64, error) {
+	info, err := os.Stat(c.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
 }
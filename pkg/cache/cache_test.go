@@ -1,8 +1,16 @@
 package cache
 
 import (
+	"bytes"
+	"fmt"
+	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
 )
 
 func TestFileCache(t *testing.T) {
@@ -11,9 +19,20 @@ func TestFileCache(t *testing.T) {
 
 	cache := NewFileCache(cacheFile)
 
+	entry := generator.Entry{
+		Source:  "hash1",
+		Command: "cmd1",
+		Tool:    "tool1",
+		Outputs: map[string]string{
+			filepath.Join(tmpDir, "mock_a.go"): "hash-a",
+			filepath.Join(tmpDir, "mock_b.go"): "hash-b",
+		},
+	}
+	key := filepath.Join(tmpDir, "test.go") + "#3"
+
 	// 测试设置和获取
-	cache.Set("test.go", "hash1")
-	if hash, exists := cache.Get("test.go"); !exists || hash != "hash1" {
+	cache.Set(key, entry)
+	if got, exists := cache.Get(key); !exists || !reflect.DeepEqual(got, entry) {
 		t.Error("cache Set/Get failed")
 	}
 
@@ -28,23 +47,36 @@ func TestFileCache(t *testing.T) {
 		t.Fatalf("unexpected error loading cache: %v", err)
 	}
 
-	if hash, exists := newCache.Get("test.go"); !exists || hash != "hash1" {
+	if got, exists := newCache.Get(key); !exists || !reflect.DeepEqual(got, entry) {
 		t.Error("cache Load failed")
 	}
 
+	// 测试空字段
+	notool := filepath.Join(tmpDir, "notool.go") + "#1"
+	cache.Set(notool, generator.Entry{Source: "hash2", Command: "cmd2"})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected error saving cache: %v", err)
+	}
+	if err := newCache.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	if got, exists := newCache.Get(notool); !exists || got.Tool != "" || got.Source != "hash2" {
+		t.Errorf("cache Load with empty field failed: %+v", got)
+	}
+
 	// 测试并发安全性
 	done := make(chan bool)
 	go func() {
 		for i := 0; i < 100; i++ {
-			cache.Set("concurrent.go", "hash")
-			cache.Get("concurrent.go")
+			cache.Set("concurrent.go#1", entry)
+			cache.Get("concurrent.go#1")
 		}
 		done <- true
 	}()
 	go func() {
 		for i := 0; i < 100; i++ {
-			cache.Set("concurrent.go", "hash")
-			cache.Get("concurrent.go")
+			cache.Set("concurrent.go#1", entry)
+			cache.Get("concurrent.go#1")
 		}
 		done <- true
 	}()
@@ -52,3 +84,134 @@ func TestFileCache(t *testing.T) {
 	<-done
 	<-done
 }
+
+func TestFileCacheSaveDeterministic(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheFile := filepath.Join(tmpDir, "nested", "test.sum")
+
+	cache := NewFileCache(cacheFile)
+	for _, key := range []string{"b.go#10", "a.go#2", "b.go#9", "a.go#10"} {
+		cache.Set(filepath.Join(tmpDir, "nested", key), generator.Entry{Source: "hash-" + key, Command: "cmd"})
+	}
+
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected error saving cache: %v", err)
+	}
+	first, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 按路径和行号排序
+	var keys []string
+	for _, line := range strings.Split(strings.TrimSpace(string(first)), "\n")[1:] {
+		keys = append(keys, strings.Fields(line)[0])
+	}
+	if want := []string{"a.go#2", "a.go#10", "b.go#9", "b.go#10"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("got keys %v, want %v", keys, want)
+	}
+
+	// 重新加载后再次保存，内容不变
+	reloaded := NewFileCache(cacheFile)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	if err := reloaded.Save(); err != nil {
+		t.Fatalf("unexpected error saving cache: %v", err)
+	}
+	second, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("cache file changed after reload and save:\n%s\n%s", first, second)
+	}
+
+	// 不残留临时文件
+	files, err := os.ReadDir(filepath.Dir(cacheFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected only the cache file, got %d files", len(files))
+	}
+}
+
+func TestFileCacheMergeOnSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheFile := filepath.Join(tmpDir, "gogen.sum")
+	key := func(name string) string { return filepath.Join(tmpDir, name) + "#1" }
+
+	base := NewFileCache(cacheFile)
+	base.Set(key("kept.go"), generator.Entry{Source: "kept"})
+	base.Set(key("deleted.go"), generator.Entry{Source: "deleted"})
+	if err := base.Save(); err != nil {
+		t.Fatalf("unexpected error saving cache: %v", err)
+	}
+
+	// 两个进程先后加载同一个缓存文件，各自修改后保存
+	first, second := NewFileCache(cacheFile), NewFileCache(cacheFile)
+	for _, c := range []*FileCache{first, second} {
+		if err := c.Load(); err != nil {
+			t.Fatalf("unexpected error loading cache: %v", err)
+		}
+	}
+	first.Set(key("first.go"), generator.Entry{Source: "first"})
+	first.Delete(key("deleted.go"))
+	second.Set(key("second.go"), generator.Entry{Source: "second"})
+	for _, c := range []*FileCache{first, second} {
+		if err := c.Save(); err != nil {
+			t.Fatalf("unexpected error saving cache: %v", err)
+		}
+	}
+
+	loaded := NewFileCache(cacheFile)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	want := []string{key("first.go"), key("kept.go"), key("second.go")}
+	if got := loaded.Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %q, want %q", got, want)
+	}
+	// 保存后合并的结果同样反映在内存中
+	if got := second.Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %q after save, want %q", got, want)
+	}
+}
+
+func TestFileCacheConcurrentSave(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheFile := filepath.Join(tmpDir, "gogen.sum")
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := NewFileCache(cacheFile)
+			if err := c.Load(); err != nil {
+				errs <- err
+				return
+			}
+			c.Set(fmt.Sprintf("%s:%d", filepath.Join(tmpDir, "a.go"), i), generator.Entry{Source: "hash"})
+			errs <- c.Save()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	loaded := NewFileCache(cacheFile)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	if got := len(loaded.Keys()); got != n {
+		t.Errorf("expected %d entries, got %d", n, got)
+	}
+}
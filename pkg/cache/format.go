@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+// 缓存文件第一行为版本头，之后每行为一条记录，格式为 "key source command tool outputs"：
+//   - key 为 "路径#序号" 或 "路径@名称"（见 generator.CommandKey），outputs 为逗号分隔的 "路径=哈希" 列表
+//   - 路径相对于项目根目录，使用 / 分隔
+//   - 字段中的空白、百分号、逗号、等号、# 和 @ 按 %XX 转义，空字段写为 "-"
+//
+// 没有版本头的文件为早期版本的 "路径 哈希" 格式，按文件记录整个文件的哈希。
+// 读取时转换为只有源文件哈希、键为文件路径的记录，由生成器在源文件没有变化时沿用（见 decodeLegacy）
+const (
+	headerPrefix = "# gogen cache v"
+	version      = 2
+)
+
+// emptyField 表示缓存文件中为空的字段
+const emptyField = "-"
+
+// encode 按当前格式编码所有记录
+func (c *FileCache) encode(entries map[string]generator.Entry) []byte {
+	lines := make(map[string]string, len(entries))
+	for key, entry := range entries {
+		lines[c.encodeKey(key)] = c.encodeLine(key, entry)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s%d\n", headerPrefix, version)
+	for _, key := range generator.SortedKeys(lines) {
+		buf.WriteString(lines[key])
+	}
+	return buf.Bytes()
+}
+
+// encodeLine 编码单条记录，日志文件使用同样的格式
+func (c *FileCache) encodeLine(key string, entry generator.Entry) string {
+	return fmt.Sprintf("%s %s %s %s %s\n", c.encodeKey(key),
+		encodeField(entry.Source), encodeField(entry.Command), encodeField(entry.Tool),
+		c.encodeOutputs(entry.Outputs))
+}
+
+// decode 解析缓存文件，格式不完整的记录会被忽略；没有版本头时按早期版本的格式解析
+func (c *FileCache) decode(content []byte) (map[string]generator.Entry, error) {
+	entries := make(map[string]generator.Entry)
+	if len(content) == 0 {
+		return entries, nil
+	}
+	lines := strings.Split(string(content), "\n")
+	if !strings.HasPrefix(lines[0], headerPrefix) {
+		return c.decodeLegacy(lines), nil
+	}
+	if v := strings.TrimPrefix(lines[0], headerPrefix); v != strconv.Itoa(version) {
+		return nil, fmt.Errorf("unsupported cache version %q", v)
+	}
+
+	for _, line := range lines[1:] {
+		key, entry, ok := c.decodeLine(line)
+		if ok {
+			entries[key] = entry
+		}
+	}
+	return entries, nil
+}
+
+// decodeLegacy 解析早期版本的 "路径 哈希" 格式。路径为绝对路径或相对于项目根目录的路径，
+// 哈希为整个源文件的哈希，转换为键为文件路径、只有 Source 的记录。保存时按当前格式重写
+func (c *FileCache) decodeLegacy(lines []string) map[string]generator.Entry {
+	entries := make(map[string]generator.Entry)
+	for _, line := range lines {
+		parts := strings.Split(line, " ")
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			entries[c.absPath(parts[0])] = generator.Entry{Source: parts[1]}
+		}
+	}
+	return entries
+}
+
+// replay 按顺序把日志中的记录应用到 entries 中，后写入的记录优先。
+// 没有以换行结尾的最后一行可能是进程退出时写入了一半的记录，与格式不完整的行一样被忽略
+func (c *FileCache) replay(entries map[string]generator.Entry, journal []byte) {
+	lines := strings.Split(string(journal), "\n")
+	for _, line := range lines[:len(lines)-1] {
+		if key, entry, ok := c.decodeLine(line); ok {
+			entries[key] = entry
+		}
+	}
+}
+
+func (c *FileCache) decodeLine(line string) (string, generator.Entry, bool) {
+	parts := strings.Split(line, " ")
+	if len(parts) != 5 {
+		return "", generator.Entry{}, false
+	}
+
+	var fields [3]string
+	for i := range fields {
+		field, err := decodeField(parts[i+1])
+		if err != nil {
+			return "", generator.Entry{}, false
+		}
+		fields[i] = field
+	}
+	key, err := c.decodeKey(parts[0])
+	if err != nil {
+		return "", generator.Entry{}, false
+	}
+	outputs, err := c.decodeOutputs(parts[4])
+	if err != nil {
+		return "", generator.Entry{}, false
+	}
+	return key, generator.Entry{
+		Source:  fields[0],
+		Command: fields[1],
+		Tool:    fields[2],
+		Outputs: outputs,
+	}, true
+}
+
+// encodeKey 把键中的绝对路径转换为相对于根目录的形式，"#序号" 或 "@名称" 后缀保持不变。
+// 路径和名称中的 # 和 @ 被转义，解码时按最后一个未转义的分隔符拆分
+func (c *FileCache) encodeKey(key string) string {
+	path, suffix := generator.SplitKey(key)
+	if suffix == "" {
+		return escape(c.relPath(key))
+	}
+	return escape(c.relPath(path)) + suffix[:1] + escape(suffix[1:])
+}
+
+func (c *FileCache) decodeKey(s string) (string, error) {
+	path, suffix := generator.SplitKey(s)
+	path, err := url.PathUnescape(path)
+	if err != nil {
+		return "", err
+	}
+	if suffix == "" {
+		return c.absPath(path), nil
+	}
+	name, err := url.PathUnescape(suffix[1:])
+	if err != nil {
+		return "", err
+	}
+	return c.absPath(path) + suffix[:1] + name, nil
+}
+
+// relPath 返回相对于根目录、以 / 分隔的路径，无法转换时（例如位于其他盘符）保持不变
+func (c *FileCache) relPath(path string) string {
+	if !filepath.IsAbs(path) {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(c.root, path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// absPath 把缓存文件中的路径转换为绝对路径
+func (c *FileCache) absPath(path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.root, path)
+}
+
+func encodeField(s string) string {
+	switch s {
+	case "":
+		return emptyField
+	case emptyField:
+		return "%2D"
+	}
+	return escape(s)
+}
+
+func decodeField(s string) (string, error) {
+	if s == emptyField {
+		return "", nil
+	}
+	return url.PathUnescape(s)
+}
+
+func (c *FileCache) encodeOutputs(outputs map[string]string) string {
+	if len(outputs) == 0 {
+		return emptyField
+	}
+
+	pairs := make([]string, 0, len(outputs))
+	for path, hash := range outputs {
+		pairs = append(pairs, escape(c.relPath(path))+"="+escape(hash))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (c *FileCache) decodeOutputs(s string) (map[string]string, error) {
+	if s == emptyField {
+		return nil, nil
+	}
+
+	outputs := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		path, hash, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		path, err := url.PathUnescape(path)
+		if err != nil {
+			return nil, err
+		}
+		hash, err = url.PathUnescape(hash)
+		if err != nil {
+			return nil, err
+		}
+		outputs[c.absPath(path)] = hash
+	}
+	return outputs, nil
+}
+
+// escape 把控制字符、空格、百分号以及作为分隔符的逗号、等号、# 和 @ 转义为 %XX
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch <= ' ', ch == 0x7f, ch == '%', ch == ',', ch == '=', ch == '#', ch == '@':
+			fmt.Fprintf(&b, "%%%02X", ch)
+		default:
+			b.WriteByte(ch)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+func TestFileCacheRelativePaths(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "my project")
+	cacheFile := filepath.Join(root, "gen", "gogen.sum")
+
+	key := filepath.Join(root, "pkg", "a b.go") + "#12"
+	entry := generator.Entry{
+		Source:  "xxhash:1",
+		Command: "-",
+		Tool:    "tool=1,2",
+		Outputs: map[string]string{filepath.Join(root, "pkg", "mocks", "mock a.go"): "xxhash:2"},
+	}
+
+	// 路径中的 # 和 @ 被转义，不会与键的后缀混淆
+	named := filepath.Join(root, "pkg", "c#1.go") + "@api"
+
+	cache := NewFileCacheWithOptions(cacheFile, Options{Root: root})
+	cache.Set(key, entry)
+	cache.Set(named, generator.Entry{Source: "xxhash:3"})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected error saving cache: %v", err)
+	}
+
+	content, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# gogen cache v2\n" +
+		"pkg/a%20b.go#12 xxhash:1 %2D tool%3D1%2C2 pkg/mocks/mock%20a.go=xxhash:2\n" +
+		"pkg/c%231.go@api xxhash:3 - - -\n"
+	if string(content) != want {
+		t.Errorf("got cache file\n%s\nwant\n%s", content, want)
+	}
+
+	// 移动项目后缓存仍然有效
+	moved := filepath.Join(t.TempDir(), "moved")
+	if err := os.Rename(root, moved); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewFileCacheWithOptions(filepath.Join(moved, "gen", "gogen.sum"), Options{Root: moved})
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	movedEntry := entry
+	movedEntry.Outputs = map[string]string{filepath.Join(moved, "pkg", "mocks", "mock a.go"): "xxhash:2"}
+	got, exists := loaded.Get(filepath.Join(moved, "pkg", "a b.go") + "#12")
+	if !exists || !reflect.DeepEqual(got, movedEntry) {
+		t.Errorf("got %+v (exists %v), want %+v", got, exists, movedEntry)
+	}
+	if _, exists := loaded.Get(filepath.Join(moved, "pkg", "c#1.go") + "@api"); !exists {
+		t.Error("expected named entry to survive the round trip")
+	}
+}
+
+func TestFileCacheMigrateLegacy(t *testing.T) {
+	root := t.TempDir()
+	cacheFile := filepath.Join(root, "gen", "mockgen.sum")
+	if err := os.MkdirAll(filepath.Dir(cacheFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// 早期版本按文件记录整个文件的哈希，路径为绝对路径或相对于运行目录的路径
+	legacy := filepath.Join(root, "a.go") + " xxhash:1\npkg/b.go xxhash:2\n"
+	if err := os.WriteFile(cacheFile, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewFileCacheWithOptions(cacheFile, Options{Root: root})
+	if err := cache.Load(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		filepath.Join(root, "a.go"):        "xxhash:1",
+		filepath.Join(root, "pkg", "b.go"): "xxhash:2",
+	}
+	for key, source := range want {
+		if got, exists := cache.Get(key); !exists || !reflect.DeepEqual(got, generator.Entry{Source: source}) {
+			t.Errorf("%s: got %+v (exists %v), want source %s", key, got, exists, source)
+		}
+	}
+
+	// Load 不修改文件，Save 时按当前格式重写
+	content, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != legacy {
+		t.Errorf("expected Load to leave the legacy file untouched, got\n%s", content)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# gogen cache v2\na.go xxhash:1 - - -\npkg/b.go xxhash:2 - - -\n"; string(content) != want {
+		t.Errorf("got migrated cache file\n%s\nwant\n%s", content, want)
+	}
+}
+
+func TestFileCacheUnsupportedVersion(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "gogen.sum")
+	if err := os.WriteFile(cacheFile, []byte("# gogen cache v9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := NewFileCache(cacheFile).Load()
+	if err == nil || !strings.Contains(err.Error(), `unsupported cache version "9"`) {
+		t.Errorf("expected unsupported version error, got %v", err)
+	}
+}
+
+func TestFileCacheImport(t *testing.T) {
+	root := t.TempDir()
+	cacheFile := filepath.Join(root, "gogen.sum")
+	legacy := filepath.Join(root, "mockgen.sum")
+	// 早期版本按生成工具保存的缓存文件
+	if err := os.WriteFile(legacy, []byte(filepath.Join(root, "a.go")+" xxhash:1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{Root: root, Import: []string{legacy, filepath.Join(root, "stringer.sum")}}
+
+	cache := NewFileCacheWithOptions(cacheFile, opts)
+	if err := cache.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := cache.Get(filepath.Join(root, "a.go")); !exists {
+		t.Fatal("expected entry imported from the per-generator cache file")
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# gogen cache v2\na.go xxhash:1 - - -\n"; string(content) != want {
+		t.Errorf("got cache file\n%s\nwant\n%s", content, want)
+	}
+
+	// 缓存文件存在后不再导入
+	if err := os.WriteFile(legacy, []byte(filepath.Join(root, "b.go")+" xxhash:2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewFileCacheWithOptions(cacheFile, opts)
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := loaded.Get(filepath.Join(root, "b.go")); exists {
+		t.Error("expected import to be skipped once the cache file exists")
+	}
+}
@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+// journalPath 返回日志文件的路径。日志记录上次保存之后 Set 的记录，每行格式与缓存文件相同，
+// Load 和 Save 时合并到缓存文件中并删除
+func (c *FileCache) journalPath() string {
+	return c.path + ".journal"
+}
+
+// appendJournal 把记录追加到日志文件。追加时持有缓存目录的共享锁，多个进程可以同时追加，
+// Load 和 Save 合并日志时持有排他锁。日志只用于恢复，写入失败时忽略，Save 仍然会保存所有记录
+func (c *FileCache) appendJournal(key string, entry generator.Entry) {
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	unlock, err := lockDir(dir, false)
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(c.journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	// 每条记录一次写入，进程被杀死时最多留下不完整的最后一行，重放时会被忽略
+	f.WriteString(c.encodeLine(key, entry))
+}
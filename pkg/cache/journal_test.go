@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+func TestFileCacheJournal(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheFile := filepath.Join(tmpDir, "gogen.sum")
+	saved := filepath.Join(tmpDir, "saved.go") + "#1"
+	pending := filepath.Join(tmpDir, "pending.go") + "#1"
+
+	cache := NewFileCache(cacheFile)
+	cache.Set(saved, generator.Entry{Source: "saved"})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("unexpected error saving cache: %v", err)
+	}
+	if _, err := os.Stat(cache.journalPath()); !os.IsNotExist(err) {
+		t.Errorf("expected journal to be removed after save, got %v", err)
+	}
+
+	// 保存前进程退出：记录只存在于日志中，最后一行只写入了一半
+	cache.Set(pending, generator.Entry{Source: "pending", Outputs: map[string]string{filepath.Join(tmpDir, "out.go"): "hash"}})
+	f, err := os.OpenFile(cache.journalPath(), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("partial.go:1 hash cmd tool out.go=ha")
+	f.Close()
+
+	loaded := NewFileCache(cacheFile)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	want := []string{pending, saved}
+	if got := loaded.Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %q, want %q", got, want)
+	}
+	if got, _ := loaded.Get(pending); got.Source != "pending" || len(got.Outputs) != 1 {
+		t.Errorf("unexpected replayed entry %+v", got)
+	}
+
+	// Load 把日志合并到缓存文件中并删除日志
+	if _, err := os.Stat(cache.journalPath()); !os.IsNotExist(err) {
+		t.Errorf("expected journal to be compacted on load, got %v", err)
+	}
+	compacted := NewFileCache(cacheFile)
+	if err := compacted.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	if got := compacted.Keys(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %q after compaction, want %q", got, want)
+	}
+}
+
+func TestFileCacheReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheFile := filepath.Join(tmpDir, "gogen.sum")
+	saved := filepath.Join(tmpDir, "saved.go") + "#1"
+	pending := filepath.Join(tmpDir, "pending.go") + "#1"
+
+	cache := NewFileCache(cacheFile)
+	cache.Set(saved, generator.Entry{Source: "saved"})
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	cache.Set(pending, generator.Entry{Source: "pending"})
+	content, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal, err := os.ReadFile(cache.journalPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只读加载在内存中重放日志，不修改缓存文件和日志
+	readOnly := NewFileCacheWithOptions(cacheFile, Options{ReadOnly: true})
+	if err := readOnly.Load(); err != nil {
+		t.Fatalf("unexpected error loading cache: %v", err)
+	}
+	if got, want := readOnly.Keys(), []string{pending, saved}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %q, want %q", got, want)
+	}
+	readOnly.Set(filepath.Join(tmpDir, "other.go")+"#1", generator.Entry{Source: "other"})
+	if err := readOnly.Save(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("expected ErrReadOnly, got %v", err)
+	}
+
+	for path, want := range map[string][]byte{cacheFile: content, cache.journalPath(): journal} {
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s changed by read-only cache:\n%s\nwant\n%s", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cache
+
+// lockDir 在不支持 flock 的平台上不加锁，保存时仍然会合并其他进程的记录
+func lockDir(dir string, exclusive bool) (func(), error) {
+	return func() {}, nil
+}
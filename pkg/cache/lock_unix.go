@@ -0,0 +1,36 @@
+//go:build unix
+
+package cache
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockDir 对目录加建议锁，exclusive 为 false 时加共享锁，阻塞直到获得锁，返回释放锁的函数。
+// 缓存文件通过重命名替换，锁住文件本身无法阻止其他进程打开替换后的新文件，因此锁加在目录上
+func lockDir(dir string, exclusive bool) (func(), error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err = syscall.Flock(int(f.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			break
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
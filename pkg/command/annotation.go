@@ -0,0 +1,72 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// annotationPrefix 是 gogen 注解的前缀，注解写在 go generate 指令之前紧邻的注释行中
+const annotationPrefix = "//gogen:"
+
+// annotations 记录一条指令的注解
+type annotations struct {
+	// id 为指令的名称，供其他指令的 after 注解引用
+	id string
+	// after 为需要先执行的指令，可以是 id、模式或工具名
+	after []string
+	// timeout 为指令的超时时间，优先于模式和全局配置
+	timeout time.Duration
+}
+
+// empty 判断是否没有任何注解
+func (a annotations) empty() bool {
+	return a.id == "" && len(a.after) == 0 && a.timeout == 0
+}
+
+// parse 解析一行注解并记录到 a 中，不是注解的行返回 false
+func (a *annotations) parse(line string) (bool, error) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasPrefix(line, annotationPrefix) {
+		return false, nil
+	}
+
+	name, value, _ := strings.Cut(line[len(annotationPrefix):], " ")
+	values := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ','
+	})
+	switch name {
+	case "id":
+		if len(values) != 1 {
+			return true, fmt.Errorf("//gogen:id requires exactly one name")
+		}
+		if a.id != "" {
+			return true, fmt.Errorf("//gogen:id specified more than once")
+		}
+		// 名称是缓存键的一部分，不能包含键的分隔符
+		if strings.ContainsAny(values[0], `#@/\`) {
+			return true, fmt.Errorf("//gogen:id %q must not contain #, @, / or \\", values[0])
+		}
+		a.id = values[0]
+	case "after":
+		if len(values) == 0 {
+			return true, fmt.Errorf("//gogen:after requires at least one id or pattern")
+		}
+		a.after = append(a.after, values...)
+	case "timeout":
+		if len(values) != 1 {
+			return true, fmt.Errorf("//gogen:timeout requires exactly one duration")
+		}
+		if a.timeout != 0 {
+			return true, fmt.Errorf("//gogen:timeout specified more than once")
+		}
+		timeout, err := time.ParseDuration(values[0])
+		if err != nil || timeout <= 0 {
+			return true, fmt.Errorf("//gogen:timeout: invalid duration %q", values[0])
+		}
+		a.timeout = timeout
+	default:
+		return true, fmt.Errorf("unknown annotation %q", "//gogen:"+name)
+	}
+	return true, nil
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandFinderAnnotations(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `package test
+
+//gogen:id protos
+//go:generate protoc --go_out=. a.proto
+
+// 生成 mock 之前需要先生成 pb 文件
+//gogen:after protos, stringer
+//go:generate mockgen -source=a.pb.go
+//go:generate mockgen -source=b.go
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := NewFinderWithOptions(Options{MatchAll: true}).Find(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands) != 3 {
+		t.Fatalf("expected 3 commands, got %d", len(commands))
+	}
+
+	tests := []struct {
+		id    string
+		after []string
+	}{
+		{"protos", nil},
+		{"", []string{"protos", "stringer"}},
+		{"", nil},
+	}
+	for i, tt := range tests {
+		if got := commands[i].GetID(); got != tt.id {
+			t.Errorf("command %d: expected id %q, got %q", i, tt.id, got)
+		}
+		if got := commands[i].GetAfter(); !reflect.DeepEqual(got, tt.after) {
+			t.Errorf("command %d: expected after %q, got %q", i, tt.after, got)
+		}
+	}
+}
+
+func TestCommandFinderAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "unknown annotation",
+			content: "package test\n//gogen:before x\n//go:generate mockgen\n",
+			want:    `unknown annotation "//gogen:before"`,
+		},
+		{
+			name:    "missing id",
+			content: "package test\n//gogen:id\n//go:generate mockgen\n",
+			want:    "requires exactly one name",
+		},
+		{
+			name:    "invalid timeout",
+			content: "package test\n//gogen:timeout soon\n//go:generate mockgen\n",
+			want:    `invalid duration "soon"`,
+		},
+		{
+			name:    "dangling annotation",
+			content: "package test\n//gogen:id a\nvar x int\n//go:generate mockgen\n",
+			want:    "test.go:2: annotation is not followed by a //go:generate directive",
+		},
+		{
+			name:    "id with key separator",
+			content: "package test\n//gogen:id a@b\n//go:generate mockgen\n",
+			want:    `//gogen:id "a@b" must not contain`,
+		},
+		{
+			name:    "annotation before alias definition",
+			content: "package test\n//gogen:id a\n//go:generate -command gen mockgen\n//go:generate gen -source=a.go\n",
+			want:    "test.go:2: annotation is not followed by a //go:generate directive",
+		},
+		{
+			name:    "annotation at end of file",
+			content: "package test\n//go:generate mockgen\n//gogen:after a\n",
+			want:    "test.go:3: annotation is not followed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(tmpDir, "test.go"), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := NewFinder("mockgen").Find(tmpDir)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -3,59 +3,278 @@ package command
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
 )
 
+// directivePrefix 是 go generate 指令的前缀
+const directivePrefix = "//go:generate"
+
+// defaultGracePeriod 为取消指令时从通知退出到强制结束的默认等待时间
+const defaultGracePeriod = 5 * time.Second
+
 // GoGenCommand 实现了 generator.Command 接口
 type GoGenCommand struct {
 	filePath string
-	cmdStr   string
+	line     int
+	// index 为指令是文件中的第几条 //go:generate 指令，用于缓存的键
+	index  int
+	pkg    string
+	cmdStr string
+
+	// root 为查找指令时扫描的根目录，opts 为查找配置，pattern 为匹配到的模式，
+	// 直接创建的指令三者为空
+	root    string
+	opts    *Options
+	pattern *Pattern
+
+	// annotations 为指令前的 //gogen: 注解
+	annotations annotations
+
+	// words 为拆分并替换 -command 别名后、尚未展开变量的参数
+	words []string
+	// err 记录拆分指令时的错误，在执行时返回
+	err error
+}
+
+// NewCommand 创建指令，GOPACKAGE 取自文件的 package 子句，序号按文件中 line 之前的指令计算
+func NewCommand(path string, line int, cmdStr string) *GoGenCommand {
+	src, _ := os.ReadFile(path)
+	words, err := splitDirective(cmdStr)
+	cmd := newCommand(path, packageName(path, src), line, cmdStr, words, err)
+	cmd.index = directiveIndex(src, line)
+	return cmd
+}
+
+// directiveIndex 返回第 line 行的指令是文件中的第几条 //go:generate 指令，不含 -command 别名定义。
+// 文件中没有该行时按其之前的指令数加一计算
+func directiveIndex(src []byte, line int) int {
+	index := 1
+	for i, text := range strings.Split(string(src), "\n") {
+		if i+1 >= line {
+			break
+		}
+		if isDirective(text) {
+			index++
+		}
+	}
+	return index
+}
+
+// isDirective 判断一行是否为 //go:generate 指令，-command 别名定义不计入
+func isDirective(line string) bool {
+	cmdStr, ok := parseDirective(line)
+	if !ok {
+		return false
+	}
+	words, err := splitDirective(cmdStr)
+	return err != nil || len(words) == 0 || words[0] != "-command"
 }
 
-func NewCommand(path, cmdStr string) *GoGenCommand {
+func newCommand(path, pkg string, line int, cmdStr string, words []string, err error) *GoGenCommand {
 	return &GoGenCommand{
 		filePath: path,
+		line:     line,
+		pkg:      pkg,
 		cmdStr:   cmdStr,
+		words:    words,
+		err:      err,
+	}
+}
+
+// Args 返回按 go generate 规则展开变量后的参数列表，
+// 配置了输出目录时声明的输出位置已被改写
+func (c *GoGenCommand) Args() ([]string, error) {
+	if c.err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", c.filePath, c.line, c.err)
+	}
+
+	env := c.expandEnv()
+	args := make([]string, len(c.words))
+	for i, word := range c.words {
+		args[i] = os.Expand(word, func(name string) string {
+			return expandVar(env, name)
+		})
+	}
+	return c.redirect(args), nil
+}
+
+// Env 返回子进程的环境变量，依次为进程环境、配置的环境变量和 go generate 变量，
+// 重复的变量以后出现的为准
+func (c *GoGenCommand) Env() []string {
+	env := append(os.Environ(), c.configEnv()...)
+	return append(env, c.goEnv()...)
+}
+
+// configEnv 返回配置的环境变量，模式的配置覆盖全局配置
+func (c *GoGenCommand) configEnv() []string {
+	var env []string
+	if c.opts != nil {
+		env = append(env, c.opts.Env...)
+	}
+	if c.pattern != nil {
+		env = append(env, c.pattern.Env...)
+	}
+	return env
+}
+
+// timeout 返回指令的超时时间，//gogen:timeout 注解优先，其次为模式的配置，最后为全局配置
+func (c *GoGenCommand) timeout() time.Duration {
+	if c.annotations.timeout > 0 {
+		return c.annotations.timeout
+	}
+	if c.pattern != nil && c.pattern.Timeout > 0 {
+		return c.pattern.Timeout
 	}
+	if c.opts != nil {
+		return c.opts.Timeout
+	}
+	return 0
 }
 
-func (c *GoGenCommand) Execute(ctx context.Context) error {
-	args := strings.Fields(c.cmdStr)
+// gracePeriod 返回取消指令时等待其退出的时间
+func (c *GoGenCommand) gracePeriod() time.Duration {
+	if c.opts != nil && c.opts.GracePeriod > 0 {
+		return c.opts.GracePeriod
+	}
+	return defaultGracePeriod
+}
+
+// Execute 执行指令，ctx 取消或超时时先通知指令退出，超过 gracePeriod 后强制结束。
+// 超过指令或整次运行的超时时间时返回的错误包含 generator.ErrTimedOut
+func (c *GoGenCommand) Execute(ctx context.Context, stdout, stderr io.Writer) error {
+	args, err := c.Args()
+	if err != nil {
+		return err
+	}
 	if len(args) == 0 {
 		return nil
 	}
+	if err := c.prepareOutputs(); err != nil {
+		return fmt.Errorf("prepare outputs: %w", err)
+	}
 
-	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
-	cmd.Dir = filepath.Dir(c.filePath)
-
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("execute command failed: %s: %w", out, err)
+	parent, timeout := ctx, c.timeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	return nil
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = c.dir()
+	cmd.Env = c.Env()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	stop := terminateOnCancel(cmd, c.gracePeriod())
+	err = cmd.Run()
+	stop()
+	// 超时被结束的指令返回 generator.ErrTimedOut，与运行被中断区分
+	switch {
+	case err == nil:
+	case timeout > 0 && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil:
+		return fmt.Errorf("%w after %s: %w", generator.ErrTimedOut, timeout, err)
+	case parent.Err() == context.DeadlineExceeded:
+		return fmt.Errorf("%w: %w", generator.ErrTimedOut, err)
+	}
+	return err
 }
 
 func (c *GoGenCommand) GetFilePath() string {
 	return c.filePath
 }
 
+func (c *GoGenCommand) GetLine() int {
+	return c.line
+}
+
+func (c *GoGenCommand) GetIndex() int {
+	return c.index
+}
+
+// GetPattern 返回匹配到该指令的模式，仅由 MatchAll 匹配时为空
+func (c *GoGenCommand) GetPattern() string {
+	if c.pattern == nil {
+		return ""
+	}
+	return c.pattern.Prefix
+}
+
+// GetID 返回 //gogen:id 注解声明的名称
+func (c *GoGenCommand) GetID() string {
+	return c.annotations.id
+}
+
+// GetAfter 返回 //gogen:after 注解声明的需要先执行的指令
+func (c *GoGenCommand) GetAfter() []string {
+	return c.annotations.after
+}
+
 func (c *GoGenCommand) String() string {
 	return c.cmdStr
 }
 
+func (c *GoGenCommand) dir() string {
+	return filepath.Dir(c.filePath)
+}
+
+func (c *GoGenCommand) fileName() string {
+	return filepath.Base(c.filePath)
+}
+
+// Pattern 定义一个指令匹配模式及其专属配置
+type Pattern struct {
+	// Prefix 为匹配指令的命令前缀，如 mockgen
+	Prefix string
+	// Env 为该模式补充的环境变量，覆盖全局配置
+	Env []string
+	// Timeout 为该模式下单条指令的超时时间，为零时使用全局配置
+	Timeout time.Duration
+}
+
+// Options 定义命令查找配置选项
+type Options struct {
+	// Patterns 按顺序匹配指令，指令记录第一个匹配的模式
+	Patterns []Pattern
+	// MatchAll 为 true 时匹配所有指令，未匹配 Patterns 的指令没有模式
+	MatchAll bool
+
+	// OutputDir 不为空时，把指令声明的输出改写到该目录下，并保持与源码相同的目录结构
+	OutputDir string
+	// OutputFlags 为其他工具补充声明输出位置的参数，键为工具名，参数名不含前导 "-"
+	OutputFlags map[string][]string
+
+	// Include 不为空时只查找匹配的文件，Exclude 排除匹配的文件和目录，
+	// 模式匹配相对于扫描根目录、以 / 分隔的路径，"**" 匹配任意层目录
+	Include []string
+	Exclude []string
+
+	// Env 为子进程补充的环境变量，格式为 "KEY=VALUE"，同时参与指令中的变量展开
+	Env []string
+	// Timeout 为单条指令的默认超时时间，为零时不限制
+	Timeout time.Duration
+	// GracePeriod 为取消指令时从通知退出到强制结束的等待时间，为零时为 5 秒
+	GracePeriod time.Duration
+}
+
 // CommandFinder 实现命令查找功能
 type CommandFinder struct {
-	pattern string
+	opts Options
 }
 
 func NewFinder(pattern string) generator.CommandFinder {
-	return &CommandFinder{pattern: pattern}
+	return NewFinderWithOptions(Options{Patterns: []Pattern{{Prefix: pattern}}})
+}
+
+// NewFinderWithOptions 创建带配置的命令查找器，一次扫描即可查找多个模式的指令
+func NewFinderWithOptions(opts Options) *CommandFinder {
+	return &CommandFinder{opts: opts}
 }
 
 func (f *CommandFinder) Find(dir string) ([]generator.Command, error) {
@@ -64,30 +283,169 @@ func (f *CommandFinder) Find(dir string) ([]generator.Command, error) {
 		if err != nil {
 			return err
 		}
+		if !f.selected(dir, path, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
 		if !info.IsDir() && filepath.Ext(path) == ".go" {
-			cmd, err := f.findInFile(path)
+			cmds, err := f.findInFile(dir, path)
 			if err != nil {
 				return err
 			}
-			if cmd != nil {
-				commands = append(commands, cmd)
-			}
+			commands = append(commands, cmds...)
 		}
 		return nil
 	})
 	return commands, err
 }
 
-func (f *CommandFinder) findInFile(path string) (generator.Command, error) {
+// FindFile 返回 root 下单个文件中匹配的指令，文件已被删除、不是 Go 文件，
+// 或文件及其所在目录未被 Include 和 Exclude 选中时返回空
+func (f *CommandFinder) FindFile(root, path string) ([]generator.Command, error) {
+	if filepath.Ext(path) != ".go" || !f.selected(root, path, false) {
+		return nil, nil
+	}
+	for dir := filepath.Dir(path); dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if !f.selected(root, dir, true) {
+			return nil, nil
+		}
+	}
+
+	commands, err := f.findInFile(root, path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return commands, err
+}
+
+// selected 根据 Include 和 Exclude 判断是否处理该路径，目录只受 Exclude 影响
+func (f *CommandFinder) selected(root, path string, isDir bool) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+
+	if matchAny(f.opts.Exclude, rel) {
+		return false
+	}
+	return isDir || len(f.opts.Include) == 0 || matchAny(f.opts.Include, rel)
+}
+
+// findInFile 按源码顺序返回文件中所有匹配的指令，每条指令记录其行号。
+// "//go:generate -command NAME ..." 定义的别名在文件剩余部分有效，
+// 指令本身或其别名展开后以模式的前缀开头即视为匹配。
+// 指令之前紧邻的 //gogen: 注解作用于该指令
+func (f *CommandFinder) findInFile(root, path string) ([]generator.Command, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	re := regexp.MustCompile(fmt.Sprintf(`//go:generate (%s.*)`, regexp.QuoteMeta(f.pattern)))
-	matches := re.FindStringSubmatch(string(content))
-	if len(matches) > 1 {
-		return NewCommand(path, matches[1]), nil
+	var (
+		commands []generator.Command
+		aliases  map[string][]string
+		pkg      string
+		// index 为已遇到的 //go:generate 指令数，包括不匹配的指令
+		index int
+
+		// pending 为尚未作用于指令的注解，pendingLine 为其第一行的行号
+		pending     annotations
+		pendingLine int
+	)
+	for i, line := range strings.Split(string(content), "\n") {
+		if ok, err := pending.parse(line); ok {
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+			}
+			if pendingLine == 0 {
+				pendingLine = i + 1
+			}
+			continue
+		}
+
+		cmdStr, ok := parseDirective(line)
+		if !ok {
+			if !pending.empty() && !strings.HasPrefix(line, "//") {
+				return nil, fmt.Errorf("%s:%d: annotation is not followed by a %s directive", path, pendingLine, directivePrefix)
+			}
+			continue
+		}
+
+		words, err := splitDirective(cmdStr)
+		if err == nil && len(words) > 0 && words[0] == "-command" {
+			// 注解不能作用于别名定义，也不能越过它作用于之后的指令
+			if !pending.empty() {
+				return nil, fmt.Errorf("%s:%d: annotation is not followed by a %s directive", path, pendingLine, directivePrefix)
+			}
+			if len(words) < 3 {
+				return nil, fmt.Errorf("%s:%d: no command specified for -command", path, i+1)
+			}
+			if _, exists := aliases[words[1]]; exists {
+				return nil, fmt.Errorf("%s:%d: command %q multiply defined", path, i+1, words[1])
+			}
+			if aliases == nil {
+				aliases = make(map[string][]string)
+			}
+			aliases[words[1]] = words[2:]
+			continue
+		}
+
+		annotated := pending
+		pending, pendingLine = annotations{}, 0
+		index++
+
+		candidates := []string{cmdStr}
+		if len(words) > 0 {
+			if alias, ok := aliases[words[0]]; ok {
+				words = append(append([]string(nil), alias...), words[1:]...)
+				candidates = append(candidates, strings.Join(alias, " "))
+			}
+		}
+		pattern, matched := f.match(candidates)
+		if !matched {
+			continue
+		}
+
+		if commands == nil {
+			pkg = packageName(path, content)
+		}
+		cmd := newCommand(path, pkg, i+1, cmdStr, words, err)
+		cmd.root, cmd.opts, cmd.pattern = root, &f.opts, pattern
+		cmd.annotations = annotated
+		cmd.index = index
+		commands = append(commands, cmd)
+	}
+	if !pending.empty() {
+		return nil, fmt.Errorf("%s:%d: annotation is not followed by a %s directive", path, pendingLine, directivePrefix)
+	}
+	return commands, nil
+}
+
+// match 返回第一个匹配任一候选文本的模式，MatchAll 时没有匹配的模式也视为匹配
+func (f *CommandFinder) match(candidates []string) (*Pattern, bool) {
+	for i := range f.opts.Patterns {
+		p := &f.opts.Patterns[i]
+		for _, text := range candidates {
+			if strings.HasPrefix(text, p.Prefix) {
+				return p, true
+			}
+		}
+	}
+	return nil, f.opts.MatchAll
+}
+
+// parseDirective 判断一行是否为 go generate 指令，返回去掉前缀后的指令内容
+func parseDirective(line string) (string, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	if !strings.HasPrefix(line, directivePrefix) {
+		return "", false
+	}
+	rest := line[len(directivePrefix):]
+	if len(rest) == 0 || (rest[0] != ' ' && rest[0] != '\t') {
+		return "", false
 	}
-	return nil, nil
+	return rest[1:], true
 }
@@ -1,10 +1,16 @@
 package command
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
 )
 
 func TestCommandFinder(t *testing.T) {
@@ -64,13 +70,17 @@ func TestGoGenCommand(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test.go")
 
-	cmd := NewCommand(testFile, "echo test")
+	cmd := NewCommand(testFile, 1, "echo test")
 
 	// 测试命令执行
-	err := cmd.Execute(context.Background())
+	var stdout bytes.Buffer
+	err := cmd.Execute(context.Background(), &stdout, nil)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
+	if stdout.String() != "test\n" {
+		t.Errorf("expected stdout %q, got %q", "test\n", stdout.String())
+	}
 
 	// 测试文件路径获取
 	if cmd.GetFilePath() != testFile {
@@ -82,3 +92,293 @@ func TestGoGenCommand(t *testing.T) {
 		t.Errorf("expected command string 'echo test', got '%s'", cmd.String())
 	}
 }
+
+func TestCommandFinderMultipleDirectives(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `package test
+
+//go:generate mockgen -source=test.go -destination=mock_a.go A
+type A interface{}
+
+//go:generate mockgen -source=test.go -destination=mock_b.go B
+type B interface{}
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := NewFinder("mockgen").Find(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands) != 2 {
+		t.Fatalf("expected 2 commands, got %d", len(commands))
+	}
+
+	expected := []struct {
+		line   int
+		cmdStr string
+	}{
+		{3, "mockgen -source=test.go -destination=mock_a.go A"},
+		{6, "mockgen -source=test.go -destination=mock_b.go B"},
+	}
+	for i, want := range expected {
+		if commands[i].GetLine() != want.line {
+			t.Errorf("command %d: expected line %d, got %d", i, want.line, commands[i].GetLine())
+		}
+		if commands[i].String() != want.cmdStr {
+			t.Errorf("command %d: expected %q, got %q", i, want.cmdStr, commands[i].String())
+		}
+	}
+}
+
+func TestGoGenCommandExpand(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "package demo\n\n//go:generate echo $GOFILE $GOLINE $GOPACKAGE $DOLLAR{x} ${GOGEN_TEST_VAR}\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "demo.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOGEN_TEST_VAR", "from-env")
+
+	commands, err := NewFinder("echo").Find(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(commands))
+	}
+
+	cmd := commands[0].(*GoGenCommand)
+	words, err := cmd.Args()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	args := strings.Join(words, " ")
+	if want := "echo demo.go 3 demo ${x} from-env"; args != want {
+		t.Errorf("expected args %q, got %q", want, args)
+	}
+
+	env := strings.Join(cmd.Env(), "\n")
+	for _, want := range []string{"GOFILE=demo.go", "GOLINE=3", "GOPACKAGE=demo", "DOLLAR=$"} {
+		if !strings.Contains(env, want) {
+			t.Errorf("expected env to contain %q", want)
+		}
+	}
+}
+
+func TestCommandFinderAliases(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `package test
+
+//go:generate mg -source=early.go
+//go:generate -command mg mockgen "-package=my pkg"
+//go:generate mg -source=test.go
+//go:generate echo unrelated
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := NewFinder("mockgen").Find(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 别名只对定义之后的指令生效
+	if len(commands) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(commands))
+	}
+
+	args, err := commands[0].(*GoGenCommand).Args()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"mockgen", "-package=my pkg", "-source=test.go"}
+	if strings.Join(args, "|") != strings.Join(want, "|") {
+		t.Errorf("expected args %q, got %q", want, args)
+	}
+	if commands[0].GetLine() != 5 {
+		t.Errorf("expected line 5, got %d", commands[0].GetLine())
+	}
+}
+
+func TestCommandFinderDuplicateAlias(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "package test\n\n//go:generate -command mg mockgen\n//go:generate -command mg mockgen -package=x\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFinder("mockgen").Find(tmpDir); err == nil {
+		t.Error("expected error for duplicate -command definition")
+	}
+}
+
+func TestCommandFinderIncludeExclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := []string{
+		"a.go",
+		"api/b.go",
+		"api/b_test.go",
+		"vendor/x/c.go",
+		"pkg/testdata/d.go",
+	}
+	for _, name := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n//go:generate mockgen -source="+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	finder := NewFinderWithOptions(Options{
+		Patterns: []Pattern{{Prefix: "mockgen"}},
+		Include:  []string{"**/*.go"},
+		Exclude:  []string{"vendor", "**/testdata", "**/*_test.go"},
+		Env:      []string{"GOGEN_MODE=strict"},
+	})
+	commands, err := finder.Find(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var found []string
+	for _, cmd := range commands {
+		rel, _ := filepath.Rel(tmpDir, cmd.GetFilePath())
+		found = append(found, filepath.ToSlash(rel))
+	}
+	if want := []string{"a.go", "api/b.go"}; strings.Join(found, ",") != strings.Join(want, ",") {
+		t.Errorf("expected files %q, got %q", want, found)
+	}
+
+	if env := strings.Join(commands[0].(*GoGenCommand).Env(), "\n"); !strings.Contains(env, "GOGEN_MODE=strict") {
+		t.Error("expected configured env in command environment")
+	}
+}
+
+func TestCommandFinderMultiplePatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := `package test
+
+//go:generate mockgen -source=test.go
+//go:generate stringer -type=Kind
+//go:generate echo done
+`
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		patterns []string
+	}{
+		{
+			name: "multiple patterns",
+			opts: Options{
+				Patterns: []Pattern{{Prefix: "mockgen"}, {Prefix: "stringer", Timeout: time.Minute}},
+			},
+			patterns: []string{"mockgen", "stringer"},
+		},
+		{
+			name: "match all",
+			opts: Options{
+				Patterns: []Pattern{{Prefix: "stringer"}},
+				MatchAll: true,
+			},
+			patterns: []string{"", "stringer", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, err := NewFinderWithOptions(tt.opts).Find(tmpDir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var patterns []string
+			for _, cmd := range commands {
+				patterns = append(patterns, cmd.GetPattern())
+			}
+			if !reflect.DeepEqual(patterns, tt.patterns) {
+				t.Errorf("expected patterns %q, got %q", tt.patterns, patterns)
+			}
+		})
+	}
+}
+
+func TestCommandFinderFindFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"api/a.go", "vendor/x/b.go"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x\n//go:generate mockgen -source="+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	finder := NewFinderWithOptions(Options{
+		Patterns: []Pattern{{Prefix: "mockgen"}},
+		Exclude:  []string{"vendor"},
+	})
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"api/a.go", 1},
+		{"vendor/x/b.go", 0},
+		{"api/missing.go", 0},
+	}
+	for _, tt := range tests {
+		commands, err := finder.FindFile(tmpDir, filepath.Join(tmpDir, filepath.FromSlash(tt.name)))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+		if len(commands) != tt.want {
+			t.Errorf("%s: expected %d commands, got %d", tt.name, tt.want, len(commands))
+		}
+	}
+}
+
+func TestCommandFinderStableKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "test.go")
+	keys := func(content string) []string {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		commands, err := NewFinder("mockgen").Find(tmpDir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var keys []string
+		for _, cmd := range commands {
+			keys = append(keys, generator.CommandKey(cmd))
+		}
+		return keys
+	}
+
+	before := keys("package test\n//go:generate -command gen mockgen\n//go:generate stringer -type=A\n" +
+		"//go:generate mockgen -source=a.go\n//go:generate gen -source=b.go\n")
+	want := []string{path + "#2", path + "#3"}
+	if !reflect.DeepEqual(before, want) {
+		t.Fatalf("expected keys %q, got %q", want, before)
+	}
+
+	// 在指令之前增加注释和注解不改变键，声明名称的指令使用名称作为键
+	after := keys("package test\n\n// Mocks\n//go:generate -command gen mockgen\n//go:generate stringer -type=A\n" +
+		"//gogen:timeout 1m\n//go:generate mockgen -source=a.go\n//gogen:id b\n//go:generate gen -source=b.go\n")
+	want = []string{path + "#2", path + "@b"}
+	if !reflect.DeepEqual(after, want) {
+		t.Errorf("expected keys %q, got %q", want, after)
+	}
+
+	if got := NewCommand(path, 7, "mockgen -source=a.go").GetIndex(); got != 2 {
+		t.Errorf("expected NewCommand to count preceding directives, got index %d", got)
+	}
+}
@@ -0,0 +1,77 @@
+package command
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// goroot 返回 GOROOT，优先使用环境变量，其次询问 go 命令
+var goroot = sync.OnceValue(func() string {
+	if v := os.Getenv("GOROOT"); v != "" {
+		return v
+	}
+	out, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+})
+
+// packageName 解析文件的 package 子句，解析失败时返回空字符串
+func packageName(path string, src []byte) string {
+	f, err := parser.ParseFile(token.NewFileSet(), path, src, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	return f.Name.Name
+}
+
+// goEnv 返回 go generate 为指令设置的变量，顺序与 go generate 保持一致
+func (c *GoGenCommand) goEnv() []string {
+	goarch, goos := os.Getenv("GOARCH"), os.Getenv("GOOS")
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+
+	return []string{
+		"GOROOT=" + goroot(),
+		"GOARCH=" + goarch,
+		"GOOS=" + goos,
+		"GOFILE=" + c.fileName(),
+		"GOLINE=" + strconv.Itoa(c.line),
+		"GOPACKAGE=" + c.pkg,
+		"DOLLAR=$",
+		"PWD=" + c.dir(),
+	}
+}
+
+// expandEnv 返回展开变量时查找的变量列表，go generate 变量优先于配置的环境变量，
+// 配置的环境变量按倒序排列，使后出现的配置优先匹配
+func (c *GoGenCommand) expandEnv() []string {
+	env := c.goEnv()
+	config := c.configEnv()
+	for i := len(config) - 1; i >= 0; i-- {
+		env = append(env, config[i])
+	}
+	return env
+}
+
+// expandVar 展开单个变量，先查找 env，再回退到进程环境变量
+func expandVar(env []string, name string) string {
+	prefix := name + "="
+	for _, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			return e[len(prefix):]
+		}
+	}
+	return os.Getenv(name)
+}
@@ -0,0 +1,47 @@
+package command
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob 判断以 / 分隔的相对路径是否匹配模式，"**" 匹配任意层目录，
+// 其余部分按 path.Match 的规则逐段匹配
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range segments {
+				if matchSegments(pattern, segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// matchAny 判断路径是否匹配任一模式
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package command
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"vendor", "vendor", true},
+		{"vendor", "pkg/vendor", false},
+		{"**/vendor", "pkg/vendor", true},
+		{"**/vendor", "vendor", true},
+		{"pkg/*.go", "pkg/a.go", true},
+		{"pkg/*.go", "pkg/sub/a.go", false},
+		{"pkg/**/*.go", "pkg/sub/deep/a.go", true},
+		{"pkg/**", "pkg/sub/a.go", true},
+		{"**/*_test.go", "a_test.go", true},
+		{"internal/**/mock_*.go", "internal/x/service.go", false},
+	}
+
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
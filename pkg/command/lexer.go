@@ -0,0 +1,57 @@
+package command
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	errBadBackslash  = errors.New("bad backslash")
+	errBadQuoted     = errors.New("bad quoted string")
+	errNoSpaceQuoted = errors.New("expect space after quoted argument")
+	errMismatchQuote = errors.New("mismatched quoted string")
+)
+
+// splitDirective 按 go generate 的规则把指令拆分为参数：
+// 参数以空格或制表符分隔，以双引号开头的参数按 Go 字符串字面量解析，
+// 结束引号后必须是空白或行尾，参数中间出现的引号按普通字符处理
+func splitDirective(line string) ([]string, error) {
+	var words []string
+Words:
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if len(line) == 0 {
+			return words, nil
+		}
+		if line[0] == '"' {
+			for i := 1; i < len(line); i++ {
+				switch line[i] {
+				case '\\':
+					if i+1 == len(line) {
+						return nil, errBadBackslash
+					}
+					i++
+				case '"':
+					word, err := strconv.Unquote(line[:i+1])
+					if err != nil {
+						return nil, errBadQuoted
+					}
+					words = append(words, word)
+					line = line[i+1:]
+					if len(line) > 0 && line[0] != ' ' && line[0] != '\t' {
+						return nil, errNoSpaceQuoted
+					}
+					continue Words
+				}
+			}
+			return nil, errMismatchQuote
+		}
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			i = len(line)
+		}
+		words = append(words, line[:i])
+		line = line[i:]
+	}
+}
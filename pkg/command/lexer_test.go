@@ -0,0 +1,63 @@
+package command
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitDirective(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "plain words",
+			line: "mockgen  -source=a.go\t-destination=mock_a.go",
+			want: []string{"mockgen", "-source=a.go", "-destination=mock_a.go"},
+		},
+		{
+			name: "quoted word with spaces",
+			line: `mockgen "-destination=mocks/my file.go"`,
+			want: []string{"mockgen", "-destination=mocks/my file.go"},
+		},
+		{
+			name: "quotes inside a word are kept",
+			line: `protoc --go_opt=paths=source_relative,M"a=b" x.proto`,
+			want: []string{"protoc", `--go_opt=paths=source_relative,M"a=b"`, "x.proto"},
+		},
+		{
+			name: "escape sequences",
+			line: `echo "a\"b\tc"`,
+			want: []string{"echo", "a\"b\tc"},
+		},
+		{
+			name:    "mismatched quote",
+			line:    `echo "abc`,
+			wantErr: errMismatchQuote,
+		},
+		{
+			name:    "missing space after quote",
+			line:    `echo "abc"def`,
+			wantErr: errNoSpaceQuoted,
+		},
+		{
+			name:    "trailing backslash",
+			line:    `echo "abc\`,
+			wantErr: errBadBackslash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := splitDirective(tt.line)
+			if err != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// outputFlags 列出已知工具声明输出位置的参数
+var outputFlags = map[string][]string{
+	"mockgen":  {"destination"},
+	"stringer": {"output"},
+	"protoc":   {"go_out", "go-grpc_out"},
+}
+
+// Outputs 返回指令通过参数声明的输出文件或目录，相对路径按指令所在目录解析
+func (c *GoGenCommand) Outputs() []string {
+	args, err := c.Args()
+	if err != nil || len(args) == 0 {
+		return nil
+	}
+
+	var outputs []string
+	mapOutputs(args, c.outputFlags(args[0]), func(value string) string {
+		outputs = append(outputs, c.abs(value))
+		return value
+	})
+	return outputs
+}
+
+// outputFlags 返回工具声明输出位置的参数，包括配置中补充的参数
+func (c *GoGenCommand) outputFlags(tool string) []string {
+	tool = filepath.Base(tool)
+	names := outputFlags[tool]
+	if c.opts != nil {
+		names = append(slices.Clip(names), c.opts.OutputFlags[tool]...)
+	}
+	return names
+}
+
+// redirect 把参数中声明的输出改写到输出目录下，保持相对于扫描根目录的结构，
+// 扫描根目录之外的输出保持不变
+func (c *GoGenCommand) redirect(args []string) []string {
+	if c.opts == nil || c.opts.OutputDir == "" || len(args) == 0 {
+		return args
+	}
+
+	root, err := filepath.Abs(c.root)
+	if err != nil {
+		return args
+	}
+	outputDir, err := filepath.Abs(c.opts.OutputDir)
+	if err != nil {
+		return args
+	}
+
+	return mapOutputs(args, c.outputFlags(args[0]), func(value string) string {
+		rel, err := filepath.Rel(root, c.abs(value))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return value
+		}
+		return filepath.Join(outputDir, rel)
+	})
+}
+
+// prepareOutputs 在执行前创建重定向后的输出目录，
+// 部分工具（如 protoc、stringer）不会自动创建目录
+func (c *GoGenCommand) prepareOutputs() error {
+	if c.opts == nil || c.opts.OutputDir == "" {
+		return nil
+	}
+
+	for _, output := range c.Outputs() {
+		dir := output
+		if filepath.Ext(output) != "" {
+			dir = filepath.Dir(output)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abs 返回按指令所在目录解析后的绝对路径
+func (c *GoGenCommand) abs(path string) string {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.dir(), path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return filepath.Clean(path)
+}
+
+// mapOutputs 对参数中声明的每个输出位置调用 fn，并用返回值替换该位置。
+// protoc 的 "--go_out=opts:dir" 写法只处理冒号后的目录，其他工具的参数值整体作为路径
+func mapOutputs(args, names []string, fn func(string) string) []string {
+	out := slices.Clone(args)
+	protoc := len(out) > 0 && filepath.Base(out[0]) == "protoc"
+	for i := 1; i < len(out); i++ {
+		value, name, next, ok := flagValue(out, i, names)
+		if !ok {
+			continue
+		}
+
+		prefix := ""
+		if protoc && strings.HasSuffix(name, "_out") {
+			prefix, value = splitProtocOut(value)
+		}
+		if value != "" {
+			value = prefix + fn(value)
+			if next == i {
+				out[i] = out[i][:strings.Index(out[i], "=")+1] + value
+			} else {
+				out[next] = value
+			}
+		}
+		i = next
+	}
+	return out
+}
+
+// splitProtocOut 把 protoc 输出参数的值拆分为 "opts:" 前缀和目录。
+// 没有选项的 Windows 盘符路径（如 C:\gen）整体作为目录
+func splitProtocOut(value string) (string, string) {
+	if len(value) >= 3 && value[1] == ':' && (value[2] == '\\' || value[2] == '/') {
+		return "", value
+	}
+	if opts, dir, ok := strings.Cut(value, ":"); ok {
+		return opts + ":", dir
+	}
+	return "", value
+}
+
+// flagValue 判断 args[i] 是否为 names 中的参数，支持 -name=value、--name=value
+// 和 -name value 三种写法，返回参数值、匹配的参数名及参数占用的最后一个位置
+func flagValue(args []string, i int, names []string) (string, string, int, bool) {
+	arg := args[i]
+	if !strings.HasPrefix(arg, "-") {
+		return "", "", i, false
+	}
+	arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+
+	for _, name := range names {
+		if arg == name {
+			if i+1 < len(args) {
+				return args[i+1], name, i + 1, true
+			}
+			return "", name, i, true
+		}
+		if strings.HasPrefix(arg, name+"=") {
+			return arg[len(name)+1:], name, i, true
+		}
+	}
+	return "", "", i, false
+}
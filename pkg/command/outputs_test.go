@@ -0,0 +1,135 @@
+package command
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGoGenCommandOutputs(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "test.go")
+
+	tests := []struct {
+		name   string
+		cmdStr string
+		want   []string
+	}{
+		{
+			name:   "mockgen destination with equals",
+			cmdStr: "mockgen -source=test.go -destination=mocks/mock_test.go",
+			want:   []string{filepath.Join(tmpDir, "mocks", "mock_test.go")},
+		},
+		{
+			name:   "mockgen destination as separate argument",
+			cmdStr: "mockgen --destination mock_test.go -source=test.go",
+			want:   []string{filepath.Join(tmpDir, "mock_test.go")},
+		},
+		{
+			name:   "stringer output",
+			cmdStr: "stringer -type=Kind -output=kind_string.go",
+			want:   []string{filepath.Join(tmpDir, "kind_string.go")},
+		},
+		{
+			name:   "protoc output directory with options",
+			cmdStr: "protoc --go_out=paths=source_relative:gen --go-grpc_out gen simple.proto",
+			want:   []string{filepath.Join(tmpDir, "gen"), filepath.Join(tmpDir, "gen")},
+		},
+		{
+			name:   "mockgen destination containing a colon",
+			cmdStr: "mockgen -source=test.go -destination=mocks/v1:mock_test.go",
+			want:   []string{filepath.Join(tmpDir, "mocks", "v1:mock_test.go")},
+		},
+		{
+			name:   "absolute path",
+			cmdStr: "mockgen -destination=/tmp/mock.go",
+			want:   []string{"/tmp/mock.go"},
+		},
+		{
+			name:   "unknown tool",
+			cmdStr: "echo -destination=x.go",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewCommand(file, 1, tt.cmdStr).Outputs()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected outputs %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCommandFinderRedirect(t *testing.T) {
+	srcDir := t.TempDir()
+	outDir := t.TempDir()
+	pkgDir := filepath.Join(srcDir, "pkg", "a")
+	if err := os.MkdirAll(pkgDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	content := `package a
+
+//go:generate mockgen -source=a.go -destination=mocks/mock_a.go
+//go:generate protoc --go_out=paths=source_relative:. a.proto
+//go:generate stringer -type=Kind -output /elsewhere/kind_string.go
+//go:generate sqlc -out gen
+`
+	if err := os.WriteFile(filepath.Join(pkgDir, "a.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	finder := NewFinderWithOptions(Options{
+		MatchAll:    true,
+		OutputDir:   outDir,
+		OutputFlags: map[string][]string{"sqlc": {"out"}},
+	})
+	commands, err := finder.Find(srcDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][]string{
+		{"mockgen", "-source=a.go", "-destination=" + filepath.Join(outDir, "pkg", "a", "mocks", "mock_a.go")},
+		{"protoc", "--go_out=paths=source_relative:" + filepath.Join(outDir, "pkg", "a"), "a.proto"},
+		{"stringer", "-type=Kind", "-output", "/elsewhere/kind_string.go"},
+		{"sqlc", "-out", filepath.Join(outDir, "pkg", "a", "gen")},
+	}
+	if len(commands) != len(want) {
+		t.Fatalf("expected %d commands, got %d", len(want), len(commands))
+	}
+	for i, cmd := range commands {
+		args, err := cmd.Args()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(args, want[i]) {
+			t.Errorf("command %d: expected args %q, got %q", i, want[i], args)
+		}
+	}
+
+	// 声明的输出同样指向输出目录
+	wantOutputs := []string{filepath.Join(outDir, "pkg", "a", "mocks", "mock_a.go")}
+	if got := commands[0].Outputs(); !reflect.DeepEqual(got, wantOutputs) {
+		t.Errorf("expected outputs %q, got %q", wantOutputs, got)
+	}
+}
+
+func TestSplitProtocOut(t *testing.T) {
+	tests := []struct {
+		value, prefix, dir string
+	}{
+		{"gen", "", "gen"},
+		{"paths=source_relative:gen", "paths=source_relative:", "gen"},
+		{`C:\gen`, "", `C:\gen`},
+		{`plugins=grpc:C:\gen`, "plugins=grpc:", `C:\gen`},
+	}
+	for _, tt := range tests {
+		if prefix, dir := splitProtocOut(tt.value); prefix != tt.prefix || dir != tt.dir {
+			t.Errorf("splitProtocOut(%q) = %q, %q, want %q, %q", tt.value, prefix, dir, tt.prefix, tt.dir)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/llamazing-cn/go-generate-manager/pkg/generator"
+)
+
+func TestGoGenCommandTerminatesProcessGroup(t *testing.T) {
+	tmpDir := t.TempDir()
+	pidFile := filepath.Join(tmpDir, "child.pid")
+	// 指令忽略 SIGTERM，启动的子进程继承该设置，只能在宽限期后被 SIGKILL 结束
+	script := "trap '' TERM\nsleep 60 &\necho $! > child.pid\nwait\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "run.sh"), []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := NewCommand(filepath.Join(tmpDir, "test.go"), 1, "sh run.sh")
+	cmd.opts = &Options{GracePeriod: 200 * time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for {
+			if _, err := os.Stat(pidFile); err == nil {
+				cancel()
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	var out bytes.Buffer
+	if err := cmd.Execute(ctx, &out, &out); err == nil {
+		t.Fatal("expected error for cancelled command")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected command to be killed after the grace period, took %s", elapsed)
+	}
+
+	content, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected child process %d to be killed", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// alive 判断进程是否仍在运行，已退出但未被回收的进程视为已结束
+func alive(pid int) bool {
+	stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+	// 状态位于进程名之后，进程名用括号包围
+	fields := strings.Fields(string(stat[bytes.LastIndexByte(stat, ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+func TestGoGenCommandTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "run.sh"), []byte("echo started\nsleep 60\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	content := "package test\n\n//gogen:timeout 200ms\n//go:generate sh run.sh\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "test.go"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 注解优先于全局配置的超时时间
+	commands, err := NewFinderWithOptions(Options{MatchAll: true, Timeout: time.Hour}).Find(tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(commands) != 1 {
+		t.Fatalf("expected 1 command, got %d", len(commands))
+	}
+
+	start := time.Now()
+	var out bytes.Buffer
+	err = commands[0].Execute(context.Background(), &out, &out)
+	if !errors.Is(err, generator.ErrTimedOut) || !strings.Contains(err.Error(), "after 200ms") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected command to be stopped after the timeout, took %s", elapsed)
+	}
+	if out.String() != "started\n" {
+		t.Errorf("expected output produced before the timeout, got %q", out.String())
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package command
+
+import (
+	"os/exec"
+	"time"
+)
+
+// terminateOnCancel 在不支持进程组信号的平台上使用默认的取消方式，直接结束指令进程
+func terminateOnCancel(cmd *exec.Cmd, grace time.Duration) (stop func()) {
+	cmd.WaitDelay = grace
+	return func() {}
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package command
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// terminateOnCancel 让指令在独立的进程组中运行。取消时向整个进程组发送 SIGTERM，
+// 经过 grace 后仍未退出的进程收到 SIGKILL，生成工具启动的子进程（如 protoc 插件、go run 编译出的程序）
+// 也会一起结束。独立的进程组同时避免终端的 Ctrl-C 直接发送给指令，由 gogen 统一处理。
+//
+// 返回的 stop 需要在 cmd.Run 返回后调用：停止尚未触发的 SIGKILL 计时器，避免宽限期结束时
+// 向已被系统复用的进程组号发送信号；此时进程组中残留的进程立即收到 SIGKILL
+func terminateOnCancel(cmd *exec.Cmd, grace time.Duration) (stop func()) {
+	var (
+		mu    sync.Mutex
+		timer *time.Timer
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		mu.Lock()
+		timer = time.AfterFunc(grace, func() {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		})
+		mu.Unlock()
+		err := syscall.Kill(-pgid, syscall.SIGTERM)
+		if errors.Is(err, syscall.ESRCH) {
+			return os.ErrProcessDone
+		}
+		return err
+	}
+	// 进程组中的其他进程可能继续持有输出管道，超过 grace 后不再等待
+	cmd.WaitDelay = grace
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil && timer.Stop() {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames 按优先级列出支持的配置文件名
+var FileNames = []string{"gogen.yaml", "gogen.yml", "gogen.toml"}
+
+// Config 定义项目配置，相对路径均相对于配置文件所在目录
+type Config struct {
+	// Path 为配置文件路径，不从文件中读取
+	Path string `yaml:"-" toml:"-"`
+
+	Dir     string            `yaml:"dir,omitempty" toml:"dir,omitempty"`
+	Output  string            `yaml:"output,omitempty" toml:"output,omitempty"`
+	Cache   string            `yaml:"cache,omitempty" toml:"cache,omitempty"`
+	Workers int               `yaml:"workers,omitempty" toml:"workers,omitempty"`
+	Timeout time.Duration     `yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	Include []string          `yaml:"include,omitempty" toml:"include,omitempty"`
+	Exclude []string          `yaml:"exclude,omitempty" toml:"exclude,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty" toml:"env,omitempty"`
+
+	// CommandTimeout 为单条指令的默认超时时间，可以被生成工具的 Timeout 或 //gogen:timeout 注解覆盖
+	CommandTimeout time.Duration `yaml:"command_timeout,omitempty" toml:"command_timeout,omitempty"`
+
+	// Format 为运行结果的输出格式：text、json 或 junit
+	Format string `yaml:"format,omitempty" toml:"format,omitempty"`
+
+	// OutputFlags 为其他工具补充声明输出位置的参数，键为工具名
+	OutputFlags map[string][]string `yaml:"output_flags,omitempty" toml:"output_flags,omitempty"`
+
+	Generators []Generator `yaml:"generators,omitempty" toml:"generators,omitempty"`
+}
+
+// Generator 定义单个生成工具的配置
+type Generator struct {
+	// Name 为匹配指令的命令前缀，如 mockgen
+	Name string `yaml:"name" toml:"name"`
+	// Workers 限制该工具的并发数，为零时使用全局配置
+	Workers int `yaml:"workers,omitempty" toml:"workers,omitempty"`
+	// Timeout 为该工具单条指令的超时时间，为零时使用全局的 CommandTimeout
+	Timeout time.Duration     `yaml:"timeout,omitempty" toml:"timeout,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty" toml:"env,omitempty"`
+}
+
+// Generator 返回指定名称的生成工具配置
+func (c *Config) Generator(name string) (Generator, bool) {
+	for _, g := range c.Generators {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return Generator{}, false
+}
+
+// Discover 从 dir 开始逐级向上查找配置文件，找不到时返回空字符串
+func Discover(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		for _, name := range FileNames {
+			path := filepath.Join(dir, name)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load 读取配置文件，按扩展名选择 YAML 或 TOML 格式，并把相对路径解析为绝对路径
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+
+	cfg := &Config{}
+	switch filepath.Ext(path) {
+	case ".toml":
+		meta, err := toml.NewDecoder(bytes.NewReader(content)).Decode(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return nil, fmt.Errorf("parse config file %s: unknown field %q", path, undecoded[0].String())
+		}
+	default:
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Path = abs
+
+	base := filepath.Dir(abs)
+	for _, p := range []*string{&cfg.Dir, &cfg.Output, &cfg.Cache} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(base, *p)
+		}
+	}
+
+	for i, g := range cfg.Generators {
+		if g.Name == "" {
+			return nil, fmt.Errorf("parse config file %s: generator %d has no name", path, i+1)
+		}
+	}
+	return cfg, nil
+}
+
+// Marshal 以 YAML 格式输出配置
+func (c *Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
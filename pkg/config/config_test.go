@@ -0,0 +1,136 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiscover(t *testing.T) {
+	tmpDir := t.TempDir()
+	nested := filepath.Join(tmpDir, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 临时目录之上可能存在配置文件，只检查没有在临时目录中找到
+	path, err := Discover(nested)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "" && filepath.Dir(path) == tmpDir {
+		t.Fatalf("unexpected config file %s", path)
+	}
+
+	configFile := filepath.Join(tmpDir, "gogen.toml")
+	if err := os.WriteFile(configFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err = Discover(nested)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != configFile {
+		t.Errorf("expected config file %s, got %s", configFile, path)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := map[string]string{
+		"gogen.yaml": `
+dir: ./src
+cache: .gogen/gogen.sum
+workers: 4
+timeout: 10m
+command_timeout: 2m
+exclude:
+  - vendor
+env:
+  GOFLAGS: -mod=mod
+generators:
+  - name: mockgen
+    workers: 2
+    timeout: 30s
+output_flags:
+  sqlc: [out]
+`,
+		"gogen.toml": `
+dir = "./src"
+cache = ".gogen/gogen.sum"
+workers = 4
+timeout = "10m"
+command_timeout = "2m"
+exclude = ["vendor"]
+
+[env]
+GOFLAGS = "-mod=mod"
+
+[[generators]]
+name = "mockgen"
+workers = 2
+timeout = "30s"
+
+[output_flags]
+sqlc = ["out"]
+`,
+	}
+
+	want := &Config{
+		Dir:     filepath.Join(tmpDir, "src"),
+		Cache:   filepath.Join(tmpDir, ".gogen", "gogen.sum"),
+		Workers: 4,
+		Timeout: 10 * time.Minute,
+		Exclude: []string{"vendor"},
+		Env:     map[string]string{"GOFLAGS": "-mod=mod"},
+
+		CommandTimeout: 2 * time.Minute,
+		OutputFlags: map[string][]string{
+			"sqlc": {"out"},
+		},
+		Generators: []Generator{
+			{Name: "mockgen", Workers: 2, Timeout: 30 * time.Second},
+		},
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(tmpDir, name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			want.Path = path
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("expected config %+v, got %+v", want, cfg)
+			}
+
+			if g, ok := cfg.Generator("mockgen"); !ok || g.Workers != 2 {
+				t.Errorf("expected mockgen generator, got %+v", g)
+			}
+		})
+	}
+}
+
+func TestLoadUnknownField(t *testing.T) {
+	files := map[string]string{
+		"gogen.yaml": "wokers: 4\n",
+		"gogen.toml": "wokers = 4\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected error for unknown field", name)
+		}
+	}
+}
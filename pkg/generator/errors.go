@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDependencyFailed 表示指令因依赖的指令失败而没有执行
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// ErrTimedOut 表示指令执行超过了超时时间而被结束，Command.Execute 返回的错误应包含它
+var ErrTimedOut = errors.New("timed out")
+
+// ExecError 记录命令执行失败时的标准输出和标准错误，其中的输出会出现在 CommandError 中
+type ExecError struct {
+	Output []byte
+	Err    error
+}
+
+func (e *ExecError) Error() string {
+	out := strings.TrimSpace(string(e.Output))
+	if out == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v\n%s", e.Err, out)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// CommandError 记录单条指令失败的位置、命令和输出
+type CommandError struct {
+	File    string
+	Line    int
+	Command string
+	// Output 为命令失败时的输出，没有执行命令时为空
+	Output string
+	Err    error
+}
+
+// newCommandError 创建指令的错误，从 ExecError 中取出命令输出
+func newCommandError(cmd Command, err error) *CommandError {
+	e := &CommandError{
+		File:    cmd.GetFilePath(),
+		Line:    cmd.GetLine(),
+		Command: cmd.String(),
+		Err:     err,
+	}
+	var execErr *ExecError
+	if errors.As(err, &execErr) {
+		e.Output = string(execErr.Output)
+	}
+	return e
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("%s:%d: %s: %v", e.File, e.Line, e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError 汇总一次生成中所有失败的指令，按指令的查找顺序排列
+type MultiError struct {
+	Errors []*CommandError
+}
+
+func (e *MultiError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "generate failed with %d errors:", len(e.Errors))
+	for _, err := range e.Errors {
+		b.WriteString("\n\t")
+		b.WriteString(err.Error())
+	}
+	return b.String()
+}
+
+// Unwrap 支持 errors.Is 和 errors.As 匹配任一指令的错误
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
@@ -1,16 +1,35 @@
 package generator
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 type DefaultGenerator struct {
-	hasher  FileHasher
-	cache   Cache
-	finder  CommandFinder
-	workers int
+	hasher   FileHasher
+	tools    ToolHasher
+	cache    Cache
+	finder   CommandFinder
+	workers  int
+	limits   map[string]int
+	graph    PackageGraph
+	rerun    bool
+	prune    bool
+	onStart  func(cmd Command)
+	onResult func(result Result)
 }
 
 // New 创建新的生成器实例
@@ -20,82 +39,419 @@ func New(opts Options) Generator {
 	}
 
 	return &DefaultGenerator{
-		hasher:  opts.Hasher,
-		cache:   opts.Cache,
-		finder:  opts.Finder,
-		workers: opts.Workers,
+		hasher:   opts.Hasher,
+		tools:    opts.Tools,
+		cache:    opts.Cache,
+		finder:   opts.Finder,
+		workers:  opts.Workers,
+		limits:   opts.Limits,
+		graph:    opts.Graph,
+		rerun:    opts.RerunDependents,
+		prune:    opts.Prune,
+		onStart:  opts.OnStart,
+		onResult: opts.OnResult,
 	}
 }
 
 // Generate 实现代码生成逻辑
 func (g *DefaultGenerator) Generate(ctx context.Context, dir string) error {
+	_, err := g.Run(ctx, dir)
+	return err
+}
+
+// Run 执行代码生成并返回每条指令的结果，有指令失败时同时返回 *MultiError
+func (g *DefaultGenerator) Run(ctx context.Context, dir string) (*Report, error) {
+	start := time.Now()
+
 	// 1. 查找所有命令
 	commands, err := g.finder.Find(dir)
 	if err != nil {
-		return fmt.Errorf("find commands: %w", err)
+		return nil, fmt.Errorf("find commands: %w", err)
+	}
+
+	deps, prev, err := g.dependencies(commands)
+	if err != nil {
+		return nil, fmt.Errorf("schedule commands: %w", err)
 	}
 
-	// 2. 并发处理文件
+	// 2. 并发处理文件，所有模式共享 worker，模式的并发限制单独计数；
+	// 每条指令等待其依赖的指令和同一目录下的前一条指令完成后再开始
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(commands))
 	semaphore := make(chan struct{}, g.workers)
+	limits := make(map[string]chan struct{})
+	for pattern, n := range g.limits {
+		if n > 0 {
+			limits[pattern] = make(chan struct{}, n)
+		}
+	}
+
+	report := &Report{Results: make([]Result, len(commands))}
+	done := make([]chan struct{}, len(commands))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
 
-	for _, cmd := range commands {
+	for i, cmd := range commands {
 		wg.Add(1)
-		go func(cmd Command) {
+		go func(i int, cmd Command) {
 			defer wg.Done()
+			defer close(done[i])
+
+			// 结果在 done[i] 关闭前写入，等待方在关闭后读取
+			result := &report.Results[i]
+			result.Command = cmd
+			if g.onResult != nil {
+				defer func() { g.onResult(*result) }()
+			}
+			cancel := func(err error) {
+				result.Status = StatusCancelled
+				result.Err = err
+			}
+
+			var changed bool
+			for _, d := range deps[i] {
+				select {
+				case <-done[d]:
+				case <-ctx.Done():
+					cancel(ctx.Err())
+					return
+				}
+				if report.Results[d].Err != nil {
+					cancel(fmt.Errorf("%w: %s", ErrDependencyFailed, CommandLocation(commands[d])))
+					return
+				}
+				changed = changed || !report.Results[d].Changes.Empty()
+			}
+			// 同一目录下的前一条指令只约束顺序，失败时本指令仍然执行
+			if p := prev[i]; p >= 0 {
+				select {
+				case <-done[p]:
+				case <-ctx.Done():
+					cancel(ctx.Err())
+					return
+				}
+			}
+
+			// 先获取模式的配额，避免等待时占用共享 worker
+			if limit, ok := limits[cmd.GetPattern()]; ok {
+				select {
+				case limit <- struct{}{}:
+					defer func() { <-limit }()
+				case <-ctx.Done():
+					cancel(ctx.Err())
+					return
+				}
+			}
 
 			select {
 			case semaphore <- struct{}{}:
 				defer func() { <-semaphore }()
 			case <-ctx.Done():
-				errChan <- ctx.Err()
+				cancel(ctx.Err())
 				return
 			}
 
-			if err := g.processCommand(ctx, cmd); err != nil {
-				errChan <- err
+			if g.onStart != nil {
+				g.onStart(cmd)
 			}
-		}(cmd)
+			g.processCommand(ctx, cmd, result, g.rerun && changed)
+		}(i, cmd)
+	}
+
+	wg.Wait()
+	// 运行被取消时没有检查所有指令，不清理缓存
+	if g.prune && ctx.Err() == nil {
+		report.Pruned = g.pruneCache(dir, commands)
 	}
+	report.Duration = time.Since(start)
+	return report, report.Err()
+}
 
-	// 等待所有任务完成
-	go func() {
-		wg.Wait()
-		close(errChan)
-	}()
+// processCommand 处理单条指令，并把结果记录到 result 中。depChanged 为 true 时缓存命中的指令也重新执行
+func (g *DefaultGenerator) processCommand(ctx context.Context, cmd Command, result *Result, depChanged bool) {
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
 
-	// 收集错误
-	var errs []error
-	for err := range errChan {
-		errs = append(errs, err)
+	fail := func(err error) {
+		switch {
+		case errors.Is(err, ErrTimedOut):
+			result.Status = StatusTimedOut
+		case ctx.Err() != nil:
+			result.Status = StatusCancelled
+		default:
+			result.Status = StatusFailed
+		}
+		result.Err = err
 	}
 
-	if len(errs) > 0 {
-		return fmt.Errorf("generate failed with %d errors: %v", len(errs), errs[0])
+	// 1. 检查指令是否需要重新执行
+	entry, old, err := g.check(cmd, result)
+	if err != nil {
+		fail(err)
+		return
+	}
+	if result.Reason == ReasonCached && depChanged {
+		result.Reason = ReasonDependencyChanged
+	}
+	if result.Reason == ReasonCached {
+		// 沿用早期版本按文件记录的缓存时补写指令的记录，之后由 Prune 删除文件的记录
+		if _, exists := g.cache.Get(CommandKey(cmd)); !exists {
+			g.cache.Set(CommandKey(cmd), old)
+		}
+		result.Status = StatusSkipped
+		result.Outputs = SortedKeys(old.Outputs)
+		return
 	}
 
-	return nil
+	// 2. 执行命令，并记录执行前后的文件变化
+	changes, err := g.execute(ctx, cmd, result)
+	if err != nil {
+		fail(fmt.Errorf("execute command: %w", err))
+		return
+	}
+
+	// 3. 更新缓存。执行前找不到的工具可能由之前的指令安装，重新计算工具哈希
+	if entry.Tool == unresolvedTool {
+		if args, err := cmd.Args(); err == nil && len(args) > 0 {
+			if tool, err := g.hashTool(cmd, args[0]); err == nil {
+				entry.Tool = tool
+			}
+		}
+	}
+	entry.Outputs, err = g.hashOutputs(append(changes.Outputs(), cmd.Outputs()...))
+	if err != nil {
+		fail(fmt.Errorf("hash outputs: %w", err))
+		return
+	}
+	g.cache.Set(CommandKey(cmd), entry)
+
+	result.Status = StatusExecuted
+	result.Outputs = SortedKeys(entry.Outputs)
+	result.Changes = changes
 }
 
-func (g *DefaultGenerator) processCommand(ctx context.Context, cmd Command) error {
+// Plan 查找指令并与缓存对比，返回每条指令是否需要执行及其原因，
+// 需要执行的指令状态为 StatusPlanned。不执行任何命令，也不修改缓存
+func (g *DefaultGenerator) Plan(ctx context.Context, dir string) (*Report, error) {
+	start := time.Now()
+
+	commands, err := g.finder.Find(dir)
+	if err != nil {
+		return nil, fmt.Errorf("find commands: %w", err)
+	}
+	if _, _, err := g.dependencies(commands); err != nil {
+		return nil, fmt.Errorf("schedule commands: %w", err)
+	}
+
+	report := &Report{Results: make([]Result, len(commands))}
+	for i, cmd := range commands {
+		result := &report.Results[i]
+		result.Command = cmd
+		if err := ctx.Err(); err != nil {
+			result.Status, result.Err = StatusCancelled, err
+			continue
+		}
+
+		checked := time.Now()
+		_, old, err := g.check(cmd, result)
+		result.Duration = time.Since(checked)
+		switch {
+		case err != nil:
+			result.Status, result.Err = StatusFailed, err
+		case result.Reason == ReasonCached:
+			result.Status = StatusSkipped
+			result.Outputs = SortedKeys(old.Outputs)
+		default:
+			result.Status = StatusPlanned
+			result.Outputs = SortedKeys(old.Outputs)
+		}
+	}
+
+	report.Duration = time.Since(start)
+	return report, report.Err()
+}
+
+// check 计算指令的指纹并与缓存记录对比，把需要执行的原因记录到 result 中
+func (g *DefaultGenerator) check(cmd Command, result *Result) (entry, old Entry, err error) {
+	entry, err = g.fingerprint(cmd)
+	if err != nil {
+		return Entry{}, Entry{}, fmt.Errorf("calculate fingerprint: %w", err)
+	}
+	old, exists := g.cache.Get(CommandKey(cmd))
+	if !exists {
+		old, exists, err = g.legacy(cmd, entry)
+		if err != nil {
+			return Entry{}, Entry{}, fmt.Errorf("check legacy cache: %w", err)
+		}
+	}
+	result.Reason = g.reason(old, exists, entry)
+	return entry, old, nil
+}
+
+// wholeHasher 由能计算整个文件哈希（不忽略指令行）的 FileHasher 实现，用于对比早期版本的缓存
+type wholeHasher interface {
+	HashWhole(path string) (string, error)
+}
+
+// legacy 查找早期版本按文件记录的缓存（键为文件路径，只有源文件哈希）。源文件没有变化
+// 且声明的输出都存在时，以当前指纹和输出补全为指令的记录返回，processCommand 跳过时写回缓存
+func (g *DefaultGenerator) legacy(cmd Command, entry Entry) (Entry, bool, error) {
 	path := cmd.GetFilePath()
+	old, exists := g.cache.Get(path)
+	if !exists || old.Command != "" {
+		return Entry{}, false, nil
+	}
+
+	hash := g.hasher.Hash
+	if h, ok := g.hasher.(wholeHasher); ok {
+		hash = h.HashWhole
+	}
+	current, err := hash(path)
+	if err != nil || current != old.Source {
+		return Entry{}, false, nil
+	}
+	for _, output := range cmd.Outputs() {
+		if _, err := os.Stat(output); os.IsNotExist(err) {
+			return Entry{}, false, nil
+		}
+	}
+
+	entry.Outputs, err = g.hashOutputs(cmd.Outputs())
+	if err != nil {
+		return Entry{}, false, err
+	}
+	return entry, true, nil
+}
+
+// execute 执行命令，并对比命令所在目录及其声明的输出位置在执行前后的快照，
+// 命令的输出和退出码记录到 result 中
+func (g *DefaultGenerator) execute(ctx context.Context, cmd Command, result *Result) (Changes, error) {
+	dir := filepath.Dir(cmd.GetFilePath())
+	dirs, roots := snapshotRoots(dir, cmd.Outputs())
+	before, err := takeSnapshot(dirs, roots)
+	if err != nil {
+		return Changes{}, fmt.Errorf("snapshot before: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	combined := &syncBuffer{}
+	err = cmd.Execute(ctx, io.MultiWriter(&stdout, combined), io.MultiWriter(&stderr, combined))
+	result.Stdout, result.Stderr = stdout.Bytes(), stderr.Bytes()
+	result.ExitCode = exitCode(err)
+	if err != nil {
+		return Changes{}, &ExecError{Output: combined.Bytes(), Err: err}
+	}
+
+	after, err := takeSnapshot(dirs, roots)
+	if err != nil {
+		return Changes{}, fmt.Errorf("snapshot after: %w", err)
+	}
+	return before.diff(after), nil
+}
+
+// exitCode 返回命令的退出码，命令没有正常退出时返回 -1
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
 
-	// 1. 检查文件是否需要重新生成
-	oldHash, exists := g.cache.Get(path)
-	if !exists || g.hasher.IsChanged(path, oldHash) {
-		// 2. 执行命令
-		if err := cmd.Execute(ctx); err != nil {
-			return fmt.Errorf("execute command: %w", err)
+// syncBuffer 是并发安全的 bytes.Buffer，用于合并标准输出和标准错误
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Bytes()
+}
+
+// snapshotRoots 根据声明的输出确定快照范围：声明的输出文件只记录其所在目录，
+// 声明的输出目录（或没有扩展名的不存在路径）递归记录
+func snapshotRoots(dir string, declared []string) (dirs, roots []string) {
+	dirs = []string{dir}
+	for _, path := range declared {
+		info, err := os.Stat(path)
+		isDir := (err == nil && info.IsDir()) || (os.IsNotExist(err) && filepath.Ext(path) == "")
+		if isDir {
+			roots = append(roots, path)
+		} else if parent := filepath.Dir(path); !slices.Contains(dirs, parent) {
+			dirs = append(dirs, parent)
 		}
+	}
+	return dirs, roots
+}
+
+// hashOutputs 计算输出文件的哈希，跳过目录和命令未生成的文件
+func (g *DefaultGenerator) hashOutputs(paths []string) (map[string]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
 
-		// 3. 更新缓存
-		newHash, err := g.hasher.Hash(path)
+	outputs := make(map[string]string, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		hash, err := g.hasher.Hash(path)
 		if err != nil {
-			return fmt.Errorf("calculate hash: %w", err)
+			return nil, err
 		}
-		g.cache.Set(path, newHash)
+		outputs[path] = hash
 	}
+	return outputs, nil
+}
 
-	return nil
+// fingerprint 计算单条指令的指纹，由源文件哈希、展开后的命令行哈希和工具二进制哈希组成，
+// 同一文件中的指令各自独立判断是否需要重新执行
+func (g *DefaultGenerator) fingerprint(cmd Command) (Entry, error) {
+	args, err := cmd.Args()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	sourceHash, err := g.hasher.Hash(cmd.GetFilePath())
+	if err != nil {
+		return Entry{}, fmt.Errorf("hash source: %w", err)
+	}
+
+	entry := Entry{
+		Source:  sourceHash,
+		Command: fmt.Sprintf("xxhash:%x", xxhash.Sum64String(strings.Join(args, "\x00"))),
+	}
+	if len(args) > 0 {
+		entry.Tool, err = g.hashTool(cmd, args[0])
+		if err != nil {
+			return Entry{}, fmt.Errorf("hash tool: %w", err)
+		}
+	}
+	return entry, nil
+}
+
+// unresolvedTool 为找不到工具时记录的工具哈希，指令总是需要执行，由执行时报告真正的错误
+const unresolvedTool = "unresolved"
+
+// hashTool 计算指令使用的工具的哈希，找不到工具时返回 unresolvedTool
+func (g *DefaultGenerator) hashTool(cmd Command, name string) (string, error) {
+	if g.tools == nil {
+		return "", nil
+	}
+	hash, err := g.tools.HashTool(name, filepath.Dir(cmd.GetFilePath()))
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return unresolvedTool, nil
+	}
+	return hash, err
 }
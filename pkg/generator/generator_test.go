@@ -2,9 +2,18 @@ package generator
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type mockHasher struct {
@@ -20,23 +29,100 @@ func (h *mockHasher) IsChanged(path, oldHash string) bool {
 	return newHash != oldHash
 }
 
+type mockToolHasher struct {
+	version string
+	missing bool
+}
+
+func (h *mockToolHasher) HashTool(name, dir string) (string, error) {
+	if h.missing {
+		return "", fmt.Errorf("resolve tool: %w", exec.ErrNotFound)
+	}
+	return name + "@" + h.version, nil
+}
+
 type mockCache struct {
-	data map[string]string
+	mu   sync.Mutex
+	data map[string]Entry
 }
 
-func (c *mockCache) Load() error                    { return nil }
-func (c *mockCache) Save() error                    { return nil }
-func (c *mockCache) Get(path string) (string, bool) { h, ok := c.data[path]; return h, ok }
-func (c *mockCache) Set(path, hash string)          { c.data[path] = hash }
+func (c *mockCache) Load() error { return nil }
+func (c *mockCache) Save() error { return nil }
+
+func (c *mockCache) Get(key string) (Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.data[key]
+	return e, ok
+}
+
+func (c *mockCache) Set(key string, entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.data[key] = entry
+}
+
+func (c *mockCache) Keys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.data))
+	for key := range c.data {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (c *mockCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.data, key)
+}
 
 type mockCommand struct {
 	path     string
+	line     int
+	cmdStr   string
+	pattern  string
+	id       string
+	after    []string
+	outputs  []string
+	writes   []string
 	executed bool
 }
 
-func (c *mockCommand) Execute(ctx context.Context) error { c.executed = true; return nil }
-func (c *mockCommand) GetFilePath() string               { return c.path }
-func (c *mockCommand) String() string                    { return "mock command" }
+func (c *mockCommand) Execute(ctx context.Context, stdout, stderr io.Writer) error {
+	c.executed = true
+	for _, path := range c.writes {
+		if err := os.WriteFile(path, []byte("generated"), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockCommand) Args() ([]string, error) { return strings.Fields(c.String()), nil }
+func (c *mockCommand) Outputs() []string       { return c.outputs }
+func (c *mockCommand) GetFilePath() string     { return c.path }
+func (c *mockCommand) GetLine() int            { return c.line }
+func (c *mockCommand) GetIndex() int           { return c.line }
+func (c *mockCommand) GetPattern() string      { return c.pattern }
+func (c *mockCommand) GetID() string           { return c.id }
+func (c *mockCommand) GetAfter() []string      { return c.after }
+func (c *mockCommand) String() string {
+	if c.cmdStr == "" {
+		return "mock command"
+	}
+	return c.cmdStr
+}
+
+// fingerprintOf 计算指令在给定哈希器下的指纹，用于预置缓存
+func fingerprintOf(t *testing.T, hasher FileHasher, tools ToolHasher, cmd Command) Entry {
+	t.Helper()
+	fp, err := (&DefaultGenerator{hasher: hasher, tools: tools}).fingerprint(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
 
 type mockFinder struct {
 	commands []Command
@@ -53,22 +139,27 @@ func TestGenerator(t *testing.T) {
 	if err := os.WriteFile(testFile, []byte("//go:generate mockgen"), 0644); err != nil {
 		t.Fatal(err)
 	}
+	outputFile := filepath.Join(tmpDir, "mock_test.go")
+	if err := os.WriteFile(outputFile, []byte("package test"), 0644); err != nil {
+		t.Fatal(err)
+	}
 
 	tests := []struct {
 		name          string
-		setupMocks    func() (FileHasher, Cache, CommandFinder)
+		setupMocks    func(t *testing.T) (FileHasher, Cache, CommandFinder)
+		tools         ToolHasher
 		expectedCalls int
 		expectError   bool
 	}{
 		{
 			name: "should regenerate when file changed",
-			setupMocks: func() (FileHasher, Cache, CommandFinder) {
-				cmd := &mockCommand{path: testFile}
+			setupMocks: func(t *testing.T) (FileHasher, Cache, CommandFinder) {
+				cmd := &mockCommand{path: testFile, line: 1}
 				return &mockHasher{
 						hashes: map[string]string{testFile: "new-hash"},
 					},
 					&mockCache{
-						data: map[string]string{testFile: "old-hash"},
+						data: map[string]Entry{CommandKey(cmd): {Source: "old-hash"}},
 					},
 					&mockFinder{
 						commands: []Command{cmd},
@@ -79,19 +170,112 @@ func TestGenerator(t *testing.T) {
 		},
 		{
 			name: "should not regenerate when file unchanged",
-			setupMocks: func() (FileHasher, Cache, CommandFinder) {
-				cmd := &mockCommand{path: testFile}
-				hash := "same-hash"
-				return &mockHasher{
-						hashes: map[string]string{testFile: hash},
+			setupMocks: func(t *testing.T) (FileHasher, Cache, CommandFinder) {
+				cmd := &mockCommand{path: testFile, line: 1}
+				hasher := &mockHasher{
+					hashes: map[string]string{testFile: "same-hash"},
+				}
+				return hasher,
+					&mockCache{
+						data: map[string]Entry{CommandKey(cmd): fingerprintOf(t, hasher, nil, cmd)},
+					},
+					&mockFinder{
+						commands: []Command{cmd},
+					}
+			},
+			expectedCalls: 0,
+			expectError:   false,
+		},
+		{
+			name: "should only rerun changed directive in the same file",
+			setupMocks: func(t *testing.T) (FileHasher, Cache, CommandFinder) {
+				cmd1 := &mockCommand{path: testFile, line: 1, cmdStr: "mockgen -source=a.go"}
+				cmd2 := &mockCommand{path: testFile, line: 2, cmdStr: "mockgen -source=b.go"}
+				hasher := &mockHasher{
+					hashes: map[string]string{testFile: "same-hash"},
+				}
+				previous := &mockCommand{path: testFile, line: 2, cmdStr: "mockgen -source=b.go -package=b"}
+				return hasher,
+					&mockCache{
+						data: map[string]Entry{
+							CommandKey(cmd1): fingerprintOf(t, hasher, nil, cmd1),
+							CommandKey(cmd2): fingerprintOf(t, hasher, nil, previous),
+						},
 					},
+					&mockFinder{
+						commands: []Command{cmd1, cmd2},
+					}
+			},
+			expectedCalls: 1,
+			expectError:   false,
+		},
+		{
+			name: "should regenerate when tool changed",
+			setupMocks: func(t *testing.T) (FileHasher, Cache, CommandFinder) {
+				cmd := &mockCommand{path: testFile, line: 1, cmdStr: "mockgen -source=a.go"}
+				hasher := &mockHasher{
+					hashes: map[string]string{testFile: "same-hash"},
+				}
+				return hasher,
 					&mockCache{
-						data: map[string]string{testFile: hash},
+						data: map[string]Entry{
+							CommandKey(cmd): fingerprintOf(t, hasher, &mockToolHasher{version: "v1"}, cmd),
+						},
 					},
 					&mockFinder{
 						commands: []Command{cmd},
 					}
 			},
+			tools:         &mockToolHasher{version: "v2"},
+			expectedCalls: 1,
+			expectError:   false,
+		},
+		{
+			name: "should regenerate when output modified",
+			setupMocks: func(t *testing.T) (FileHasher, Cache, CommandFinder) {
+				cmd := &mockCommand{path: testFile, line: 1, outputs: []string{outputFile}}
+				hasher := &mockHasher{
+					hashes: map[string]string{testFile: "same-hash", outputFile: "edited-hash"},
+				}
+				entry := fingerprintOf(t, hasher, nil, cmd)
+				entry.Outputs = map[string]string{outputFile: "output-hash"}
+				return hasher,
+					&mockCache{data: map[string]Entry{CommandKey(cmd): entry}},
+					&mockFinder{commands: []Command{cmd}}
+			},
+			expectedCalls: 1,
+			expectError:   false,
+		},
+		{
+			name: "should regenerate when output deleted",
+			setupMocks: func(t *testing.T) (FileHasher, Cache, CommandFinder) {
+				missing := filepath.Join(tmpDir, "mock_missing.go")
+				cmd := &mockCommand{path: testFile, line: 1, outputs: []string{missing}}
+				hasher := &mockHasher{
+					hashes: map[string]string{testFile: "same-hash"},
+				}
+				entry := fingerprintOf(t, hasher, nil, cmd)
+				entry.Outputs = map[string]string{missing: "output-hash"}
+				return hasher,
+					&mockCache{data: map[string]Entry{CommandKey(cmd): entry}},
+					&mockFinder{commands: []Command{cmd}}
+			},
+			expectedCalls: 1,
+			expectError:   false,
+		},
+		{
+			name: "should not regenerate when output unchanged",
+			setupMocks: func(t *testing.T) (FileHasher, Cache, CommandFinder) {
+				cmd := &mockCommand{path: testFile, line: 1, outputs: []string{outputFile}}
+				hasher := &mockHasher{
+					hashes: map[string]string{testFile: "same-hash", outputFile: "output-hash"},
+				}
+				entry := fingerprintOf(t, hasher, nil, cmd)
+				entry.Outputs = map[string]string{outputFile: "output-hash"}
+				return hasher,
+					&mockCache{data: map[string]Entry{CommandKey(cmd): entry}},
+					&mockFinder{commands: []Command{cmd}}
+			},
 			expectedCalls: 0,
 			expectError:   false,
 		},
@@ -99,9 +283,10 @@ func TestGenerator(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			hasher, cache, finder := tt.setupMocks()
+			hasher, cache, finder := tt.setupMocks(t)
 			gen := New(Options{
 				Hasher:  hasher,
+				Tools:   tt.tools,
 				Cache:   cache,
 				Finder:  finder,
 				Workers: 1,
@@ -129,3 +314,463 @@ func TestGenerator(t *testing.T) {
 		})
 	}
 }
+
+func TestGeneratorRecordsChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.go")
+	if err := os.WriteFile(testFile, []byte("//go:generate mockgen"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "mock_test.go")
+	cmd := &mockCommand{path: testFile, line: 1, writes: []string{outputFile}}
+	cache := &mockCache{data: map[string]Entry{}}
+
+	var result Result
+	gen := New(Options{
+		Hasher:  &mockHasher{hashes: map[string]string{testFile: "hash", outputFile: "output-hash"}},
+		Cache:   cache,
+		Finder:  &mockFinder{commands: []Command{cmd}},
+		Workers: 1,
+		OnResult: func(r Result) {
+			result = r
+		},
+	})
+
+	if err := gen.Generate(context.Background(), tmpDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Status != StatusExecuted || result.Reason != ReasonNew {
+		t.Errorf("expected executed with reason %q, got %s (%s)", ReasonNew, result.Status, result.Reason)
+	}
+	if !reflect.DeepEqual(result.Changes.Created, []string{outputFile}) {
+		t.Errorf("expected created %q, got %q", []string{outputFile}, result.Changes.Created)
+	}
+	if !reflect.DeepEqual(result.Outputs, []string{outputFile}) {
+		t.Errorf("expected outputs %q, got %q", []string{outputFile}, result.Outputs)
+	}
+	entry := cache.data[CommandKey(cmd)]
+	if entry.Outputs[outputFile] != "output-hash" {
+		t.Errorf("expected output recorded in cache, got %v", entry.Outputs)
+	}
+}
+
+func TestGeneratorLegacyCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.go")
+	outputFile := filepath.Join(tmpDir, "mock_test.go")
+	for _, path := range []string{testFile, outputFile} {
+		if err := os.WriteFile(path, []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hasher := &mockHasher{hashes: map[string]string{testFile: "hash", outputFile: "output-hash"}}
+
+	newCommands := func() []*mockCommand {
+		return []*mockCommand{
+			{path: testFile, line: 1, cmdStr: "mockgen", outputs: []string{outputFile}},
+			{path: testFile, line: 2, cmdStr: "stringer"},
+		}
+	}
+	run := func(t *testing.T, source string, plan bool) ([]*mockCommand, *mockCache, *Report) {
+		cmds := newCommands()
+		cache := &mockCache{data: map[string]Entry{testFile: {Source: source}}}
+		gen := New(Options{
+			Hasher: hasher,
+			Cache:  cache,
+			Finder: &mockFinder{commands: []Command{cmds[0], cmds[1]}},
+			Prune:  true,
+		})
+		var report *Report
+		var err error
+		if plan {
+			report, err = gen.Plan(context.Background(), tmpDir)
+		} else {
+			report, err = gen.Run(context.Background(), tmpDir)
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return cmds, cache, report
+	}
+
+	t.Run("unchanged", func(t *testing.T) {
+		cmds, cache, report := run(t, "hash", false)
+		for i, result := range report.Results {
+			if result.Status != StatusSkipped || cmds[i].executed {
+				t.Errorf("%s: expected skipped from legacy cache, got %s (%s)", cmds[i], result.Status, result.Reason)
+			}
+		}
+		// 补写指令的记录并删除文件的记录
+		assertKeys(t, cache, []string{CommandKey(cmds[0]), CommandKey(cmds[1])})
+		entry := cache.data[CommandKey(cmds[0])]
+		want := fingerprintOf(t, hasher, nil, cmds[0])
+		want.Outputs = map[string]string{outputFile: "output-hash"}
+		if !reflect.DeepEqual(entry, want) {
+			t.Errorf("got migrated entry %+v, want %+v", entry, want)
+		}
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		cmds, _, report := run(t, "old-hash", false)
+		for i, result := range report.Results {
+			if result.Status != StatusExecuted || result.Reason != ReasonNew {
+				t.Errorf("%s: expected executed as new, got %s (%s)", cmds[i], result.Status, result.Reason)
+			}
+		}
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		_, cache, report := run(t, "hash", true)
+		if n := report.Count(StatusSkipped); n != 2 {
+			t.Errorf("expected 2 directives planned as cached, got %d", n)
+		}
+		assertKeys(t, cache, []string{testFile})
+	})
+}
+
+func TestGeneratorToolNotFound(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.go")
+	if err := os.WriteFile(testFile, []byte("//go:generate mockgen"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := &mockCommand{path: testFile, line: 1, cmdStr: "mockgen -source=a.go"}
+	hasher := &mockHasher{hashes: map[string]string{testFile: "hash"}}
+	cache := &mockCache{data: map[string]Entry{
+		CommandKey(cmd): fingerprintOf(t, hasher, &mockToolHasher{version: "v1"}, cmd),
+	}}
+	gen := New(Options{
+		Hasher: hasher,
+		Tools:  &mockToolHasher{missing: true},
+		Cache:  cache,
+		Finder: &mockFinder{commands: []Command{cmd}},
+	})
+
+	// 找不到工具时计划执行，不报告失败
+	report, err := gen.Plan(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result := report.Results[0]; result.Status != StatusPlanned || result.Reason != ReasonToolNotFound {
+		t.Errorf("expected planned with reason %q, got %s (%s)", ReasonToolNotFound, result.Status, result.Reason)
+	}
+
+	// 执行时由命令本身报告错误，成功后仍然找不到工具时下次重新执行
+	if _, err := gen.Run(context.Background(), tmpDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cmd.executed {
+		t.Error("expected directive to run")
+	}
+	if entry := cache.data[CommandKey(cmd)]; entry.Tool != unresolvedTool {
+		t.Errorf("expected unresolved tool recorded, got %q", entry.Tool)
+	}
+}
+
+// concurrentCommand 记录同时执行的命令数
+type concurrentCommand struct {
+	mockCommand
+	mu     *sync.Mutex
+	active *int
+	peak   *int
+}
+
+func (c *concurrentCommand) Execute(ctx context.Context, stdout, stderr io.Writer) error {
+	c.mu.Lock()
+	*c.active++
+	if *c.active > *c.peak {
+		*c.peak = *c.active
+	}
+	c.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	c.mu.Lock()
+	*c.active--
+	c.mu.Unlock()
+	return nil
+}
+
+func TestGeneratorPatternLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	var (
+		mu           sync.Mutex
+		active, peak int
+	)
+	var commands []Command
+	for i := 0; i < 4; i++ {
+		dir := filepath.Join(tmpDir, fmt.Sprintf("pkg%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, "a.go")
+		commands = append(commands,
+			&concurrentCommand{mockCommand: mockCommand{path: path, line: 1, pattern: "slow"}, mu: &mu, active: &active, peak: &peak},
+			&mockCommand{path: path, line: 2, pattern: "fast"},
+		)
+	}
+
+	gen := New(Options{
+		Hasher:  &mockHasher{hashes: map[string]string{}},
+		Cache:   &mockCache{data: map[string]Entry{}},
+		Finder:  &mockFinder{commands: commands},
+		Workers: 8,
+		Limits:  map[string]int{"slow": 1},
+	})
+	if err := gen.Generate(context.Background(), tmpDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if peak != 1 {
+		t.Errorf("expected at most 1 concurrent slow command, got %d", peak)
+	}
+}
+
+type mockGraph map[string][]string
+
+func (g mockGraph) Deps(dir string) []string { return g[dir] }
+
+// orderedCommand 记录命令的执行顺序
+type orderedCommand struct {
+	mockCommand
+	mu    *sync.Mutex
+	order *[]string
+}
+
+func (c *orderedCommand) Execute(ctx context.Context, stdout, stderr io.Writer) error {
+	time.Sleep(10 * time.Millisecond)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.order = append(*c.order, c.cmdStr)
+	return nil
+}
+
+func TestGeneratorDependencyOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	dirs := map[string]string{}
+	for _, name := range []string{"api", "enum", "other"} {
+		dirs[name] = filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(dirs[name], 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	command := func(dir string, line int, cmdStr string) Command {
+		path := filepath.Join(dirs[dir], "a.go")
+		return &orderedCommand{mockCommand: mockCommand{path: path, line: line, cmdStr: cmdStr}, mu: &mu, order: &order}
+	}
+
+	// api 依赖 enum，mockgen 需要等 stringer 生成完成
+	commands := []Command{
+		command("api", 1, "mockgen"),
+		command("enum", 1, "stringer"),
+		command("enum", 2, "enumer"),
+		command("other", 1, "other"),
+	}
+	gen := New(Options{
+		Hasher:  &mockHasher{hashes: map[string]string{}},
+		Cache:   &mockCache{data: map[string]Entry{}},
+		Finder:  &mockFinder{commands: commands},
+		Workers: 4,
+		Graph:   mockGraph{dirs["api"]: {dirs["enum"]}},
+	})
+	if err := gen.Generate(context.Background(), tmpDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	index := func(name string) int { return slices.Index(order, name) }
+	if len(order) != 4 {
+		t.Fatalf("expected 4 commands executed, got %q", order)
+	}
+	if !(index("stringer") < index("enumer") && index("enumer") < index("mockgen")) {
+		t.Errorf("unexpected execution order %q", order)
+	}
+}
+
+func TestGeneratorRerunDependents(t *testing.T) {
+	tmpDir := t.TempDir()
+	api, enum := filepath.Join(tmpDir, "api"), filepath.Join(tmpDir, "enum")
+	for _, dir := range []string{api, enum} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, rerun := range []bool{false, true} {
+		// enum 中的指令重新生成了文件，api 依赖 enum，其指令的指纹没有变化
+		stringer := &mockCommand{path: filepath.Join(enum, "a.go"), line: 1, cmdStr: "stringer",
+			writes: []string{filepath.Join(enum, fmt.Sprintf("gen_%v.go", rerun))}}
+		mockgen := &mockCommand{path: filepath.Join(api, "a.go"), line: 1, cmdStr: "mockgen"}
+		hasher := &mockHasher{hashes: map[string]string{}}
+		results := make(map[Command]Result)
+		var mu sync.Mutex
+		gen := New(Options{
+			Hasher:          hasher,
+			Cache:           &mockCache{data: map[string]Entry{CommandKey(mockgen): fingerprintOf(t, hasher, nil, mockgen)}},
+			Finder:          &mockFinder{commands: []Command{mockgen, stringer}},
+			Graph:           mockGraph{api: {enum}},
+			RerunDependents: rerun,
+			OnResult: func(r Result) {
+				mu.Lock()
+				defer mu.Unlock()
+				results[r.Command] = r
+			},
+		})
+		if err := gen.Generate(context.Background(), tmpDir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := ReasonCached
+		if rerun {
+			want = ReasonDependencyChanged
+		}
+		if got := results[mockgen].Reason; got != want || mockgen.executed != rerun {
+			t.Errorf("rerun %v: expected reason %q, got %q (executed %v)", rerun, want, got, mockgen.executed)
+		}
+	}
+}
+
+// failingCommand 执行时返回错误
+type failingCommand struct {
+	mockCommand
+}
+
+func (c *failingCommand) Execute(ctx context.Context, stdout, stderr io.Writer) error {
+	fmt.Fprint(stderr, "boom")
+	return errors.New("exit status 1")
+}
+
+func TestGeneratorSkipsDependentsOfFailed(t *testing.T) {
+	tmpDir := t.TempDir()
+	enum := filepath.Join(tmpDir, "enum")
+	api := filepath.Join(tmpDir, "api")
+
+	dependent := &mockCommand{path: filepath.Join(api, "a.go"), line: 1}
+	gen := New(Options{
+		Hasher: &mockHasher{hashes: map[string]string{}},
+		Cache:  &mockCache{data: map[string]Entry{}},
+		Finder: &mockFinder{commands: []Command{
+			dependent,
+			&failingCommand{mockCommand{path: filepath.Join(enum, "a.go"), line: 1}},
+		}},
+		Workers: 2,
+		Graph:   mockGraph{api: {enum}},
+	})
+	if err := gen.Generate(context.Background(), tmpDir); err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if dependent.executed {
+		t.Error("expected dependent command not to run after its dependency failed")
+	}
+}
+
+func TestGeneratorSameFileFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "a.go")
+
+	next := &mockCommand{path: path, line: 4}
+	gen := New(Options{
+		Hasher: &mockHasher{hashes: map[string]string{}},
+		Cache:  &mockCache{data: map[string]Entry{}},
+		Finder: &mockFinder{commands: []Command{
+			&failingCommand{mockCommand{path: path, line: 3}},
+			next,
+		}},
+		Workers: 2,
+	})
+	report, err := gen.Run(context.Background(), tmpDir)
+	if err == nil {
+		t.Fatal("expected error but got none")
+	}
+	if errors.Is(err, ErrDependencyFailed) {
+		t.Errorf("expected no dependency error between directives in the same file, got %v", err)
+	}
+	if !next.executed || report.Results[1].Status != StatusExecuted {
+		t.Errorf("expected second directive to run after the first failed, got %q", report.Results[1].Status)
+	}
+}
+
+func TestGeneratorReportsAllErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	enum := filepath.Join(tmpDir, "enum")
+	api := filepath.Join(tmpDir, "api")
+
+	gen := New(Options{
+		Hasher: &mockHasher{hashes: map[string]string{}},
+		Cache:  &mockCache{data: map[string]Entry{}},
+		Finder: &mockFinder{commands: []Command{
+			&failingCommand{mockCommand{path: filepath.Join(api, "a.go"), line: 3, cmdStr: "mockgen"}},
+			&mockCommand{path: filepath.Join(api, "a.go"), line: 4},
+			&failingCommand{mockCommand{path: filepath.Join(enum, "a.go"), line: 1, cmdStr: "stringer"}},
+			&mockCommand{path: filepath.Join(tmpDir, "b.go"), line: 1},
+		}},
+		Workers: 2,
+	})
+	err := gen.Generate(context.Background(), tmpDir)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("expected *MultiError, got %T: %v", err, err)
+	}
+	if len(multi.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(multi.Errors), err)
+	}
+
+	first := multi.Errors[0]
+	if first.File != filepath.Join(api, "a.go") || first.Line != 3 || first.Command != "mockgen" || first.Output != "boom" {
+		t.Errorf("unexpected first error %+v", first)
+	}
+	if multi.Errors[1].Command != "stringer" {
+		t.Errorf("expected stringer failure, got %v", multi.Errors[1])
+	}
+
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || string(execErr.Output) != "boom" {
+		t.Errorf("expected errors.As to find *ExecError, got %v", execErr)
+	}
+}
+
+// hangingCommand 输出部分内容后超时
+type hangingCommand struct {
+	mockCommand
+}
+
+func (c *hangingCommand) Execute(ctx context.Context, stdout, stderr io.Writer) error {
+	fmt.Fprint(stdout, "partial")
+	return fmt.Errorf("%w after 1s: signal: terminated", ErrTimedOut)
+}
+
+func TestGeneratorTimedOut(t *testing.T) {
+	tmpDir := t.TempDir()
+	gen := New(Options{
+		Hasher: &mockHasher{hashes: map[string]string{}},
+		Cache:  &mockCache{data: map[string]Entry{}},
+		Finder: &mockFinder{commands: []Command{
+			&hangingCommand{mockCommand{path: filepath.Join(tmpDir, "a.go"), line: 1}},
+			&mockCommand{path: filepath.Join(tmpDir, "a.go"), line: 2},
+		}},
+		Workers: 1,
+	})
+	report, err := gen.Run(context.Background(), tmpDir)
+	if !errors.Is(err, ErrTimedOut) {
+		t.Fatalf("expected ErrTimedOut, got %v", err)
+	}
+
+	result := report.Results[0]
+	if result.Status != StatusTimedOut {
+		t.Errorf("expected status %q, got %q", StatusTimedOut, result.Status)
+	}
+	if string(result.Stdout) != "partial" {
+		t.Errorf("expected output produced before the timeout, got %q", result.Stdout)
+	}
+	if report.Results[1].Status != StatusExecuted {
+		t.Errorf("expected next directive to run, got %q: %v", report.Results[1].Status, report.Results[1].Err)
+	}
+}
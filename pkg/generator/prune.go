@@ -0,0 +1,39 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Prune 查找 dir 下的指令，删除 dir 下已不存在的指令的缓存记录，返回删除的键。
+// 不执行任何命令，dir 之外的记录保持不变
+func (g *DefaultGenerator) Prune(ctx context.Context, dir string) ([]string, error) {
+	commands, err := g.finder.Find(dir)
+	if err != nil {
+		return nil, fmt.Errorf("find commands: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return g.pruneCache(dir, commands), nil
+}
+
+// pruneCache 删除源文件位于 dir 下、但不属于 commands 中任何指令的缓存记录，
+// 例如源文件被删除、重命名或指令被移除后遗留的记录
+func (g *DefaultGenerator) pruneCache(dir string, commands []Command) []string {
+	seen := make(map[string]bool, len(commands))
+	for _, cmd := range commands {
+		seen[CommandKey(cmd)] = true
+	}
+
+	var pruned []string
+	for _, key := range g.cache.Keys() {
+		if path, _ := SplitKey(key); !seen[key] && Within(dir, path) {
+			g.cache.Delete(key)
+			pruned = append(pruned, key)
+		}
+	}
+	sort.Strings(pruned)
+	return pruned
+}
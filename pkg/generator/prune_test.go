@@ -0,0 +1,93 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGeneratorPrune(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "pkg")
+	other := filepath.Join(root, "other")
+	for _, d := range []string{dir, other} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testFile := filepath.Join(dir, "a.go")
+	if err := os.WriteFile(testFile, []byte("//go:generate mockgen"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &mockCommand{path: testFile, line: 3}
+	hasher := &mockHasher{hashes: map[string]string{testFile: "hash"}}
+	newCache := func() *mockCache {
+		return &mockCache{data: map[string]Entry{
+			CommandKey(cmd):                         fingerprintOf(t, hasher, nil, cmd),
+			testFile + "#10":                        {Source: "removed directive"},
+			filepath.Join(dir, "deleted.go") + "#1": {Source: "deleted file"},
+			filepath.Join(other, "b.go") + "#1":     {Source: "outside dir"},
+		}}
+	}
+	want := []string{testFile + "#10", filepath.Join(dir, "deleted.go") + "#1"}
+	wantKeys := []string{CommandKey(cmd), filepath.Join(other, "b.go") + "#1"}
+
+	t.Run("run", func(t *testing.T) {
+		cache := newCache()
+		gen := New(Options{Hasher: hasher, Cache: cache, Finder: &mockFinder{commands: []Command{cmd}}, Prune: true})
+		report, err := gen.Run(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(report.Pruned, want) {
+			t.Errorf("expected pruned %q, got %q", want, report.Pruned)
+		}
+		assertKeys(t, cache, wantKeys)
+	})
+
+	t.Run("partial run", func(t *testing.T) {
+		cache := newCache()
+		gen := New(Options{Hasher: hasher, Cache: cache, Finder: &mockFinder{commands: []Command{cmd}}})
+		report, err := gen.Run(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(report.Pruned) != 0 {
+			t.Errorf("expected nothing pruned without Prune, got %q", report.Pruned)
+		}
+		if len(cache.Keys()) != 4 {
+			t.Errorf("expected all entries kept, got %q", cache.Keys())
+		}
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		cache := newCache()
+		gen := New(Options{Hasher: hasher, Cache: cache, Finder: &mockFinder{commands: []Command{cmd}}})
+		pruned, err := gen.Prune(context.Background(), dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(pruned, want) {
+			t.Errorf("expected pruned %q, got %q", want, pruned)
+		}
+		assertKeys(t, cache, wantKeys)
+		if cmd.executed {
+			t.Error("expected Prune not to execute commands")
+		}
+	})
+}
+
+func assertKeys(t *testing.T, cache *mockCache, want []string) {
+	t.Helper()
+	for _, key := range want {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("expected entry %s to be kept", key)
+		}
+	}
+	if got := cache.Keys(); len(got) != len(want) {
+		t.Errorf("expected %d entries, got %q", len(want), got)
+	}
+}
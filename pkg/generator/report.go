@@ -0,0 +1,116 @@
+package generator
+
+import (
+	"os"
+	"time"
+)
+
+// Status 表示指令在一次运行中的结果
+type Status string
+
+const (
+	StatusExecuted  Status = "executed"  // 命令执行成功
+	StatusSkipped   Status = "skipped"   // 缓存命中，没有执行
+	StatusFailed    Status = "failed"    // 计算指纹或执行命令失败
+	StatusCancelled Status = "cancelled" // 运行被取消或依赖的指令失败，没有执行完成
+	StatusTimedOut  Status = "timed out" // 执行超过超时时间被结束，保留结束前的输出
+	StatusPlanned   Status = "planned"   // 只在 Plan 中使用，表示指令需要执行
+)
+
+// Reason 表示指令需要执行或可以跳过的原因
+type Reason string
+
+const (
+	ReasonNew               Reason = "new"                // 缓存中没有记录
+	ReasonSourceChanged     Reason = "source changed"     // 源文件内容变化
+	ReasonCommandChanged    Reason = "directive changed"  // 展开后的命令行变化
+	ReasonToolChanged       Reason = "tool changed"       // 工具二进制变化
+	ReasonToolNotFound      Reason = "tool not found"     // 找不到工具，执行时报告错误
+	ReasonOutputMissing     Reason = "output missing"     // 记录的输出文件被删除
+	ReasonOutputModified    Reason = "output modified"    // 记录的输出文件被修改
+	ReasonDependencyChanged Reason = "dependency changed" // 依赖的指令重新生成了文件，见 Options.RerunDependents
+	ReasonCached            Reason = "cached"             // 指纹和输出均未变化
+)
+
+// Result 记录单条指令在一次运行中的结果
+type Result struct {
+	Command Command
+	Status  Status
+	// Reason 为指令需要执行或被跳过的原因，没有计算出指纹时为空
+	Reason Reason
+	// Duration 为计算指纹和执行命令的耗时，不包括等待依赖和并发配额的时间
+	Duration time.Duration
+
+	// ExitCode 为命令的退出码，没有执行时为 0，无法取得退出码时为 -1
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+
+	// Outputs 为缓存中记录的输出文件，按路径排序
+	Outputs []string
+	// Changes 为命令执行前后的文件变化，只在执行成功时有效
+	Changes Changes
+
+	// Err 为失败或取消的原因
+	Err error
+}
+
+// Report 记录一次运行中所有指令的结果，按指令的查找顺序排列
+type Report struct {
+	Results  []Result
+	Duration time.Duration
+
+	// Pruned 为设置 Options.Prune 时从缓存中删除的过期记录的键
+	Pruned []string
+}
+
+// Count 返回指定状态的指令数
+func (r *Report) Count(status Status) int {
+	n := 0
+	for _, result := range r.Results {
+		if result.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err 汇总所有失败和取消的指令，全部成功时返回 nil
+func (r *Report) Err() error {
+	var multi MultiError
+	for _, result := range r.Results {
+		if result.Err != nil {
+			multi.Errors = append(multi.Errors, newCommandError(result.Command, result.Err))
+		}
+	}
+	if len(multi.Errors) == 0 {
+		return nil
+	}
+	return &multi
+}
+
+// reason 对比缓存记录与当前指纹，返回指令需要执行的原因，不需要执行时返回 ReasonCached
+func (g *DefaultGenerator) reason(old Entry, exists bool, entry Entry) Reason {
+	switch {
+	case entry.Tool == unresolvedTool:
+		return ReasonToolNotFound
+	case !exists:
+		return ReasonNew
+	case old.Source != entry.Source:
+		return ReasonSourceChanged
+	case old.Command != entry.Command:
+		return ReasonCommandChanged
+	case old.Tool != entry.Tool:
+		return ReasonToolChanged
+	}
+
+	for _, path := range SortedKeys(old.Outputs) {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return ReasonOutputMissing
+		}
+		if g.hasher.IsChanged(path, old.Outputs[path]) {
+			return ReasonOutputModified
+		}
+	}
+	return ReasonCached
+}
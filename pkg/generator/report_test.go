@@ -0,0 +1,174 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// shellCommand 通过 sh 执行脚本
+type shellCommand struct {
+	mockCommand
+	script string
+}
+
+func (c *shellCommand) Execute(ctx context.Context, stdout, stderr io.Writer) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.script)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	return cmd.Run()
+}
+
+func TestGeneratorRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not installed, skipping tests")
+	}
+
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.go")
+	hasher := &mockHasher{hashes: map[string]string{testFile: "hash"}}
+
+	cached := &mockCommand{path: testFile, line: 1, cmdStr: "cached"}
+	executed := &shellCommand{mockCommand{path: testFile, line: 2, cmdStr: "ok"}, "echo hello"}
+	failed := &shellCommand{mockCommand{path: testFile, line: 3, cmdStr: "fail"}, "echo out; echo err >&2; exit 3"}
+	// 同一文件中的指令只按顺序执行，只有声明了依赖的指令在失败后被取消
+	cancelled := &mockCommand{path: testFile, line: 4, cmdStr: "after", after: []string{"fail"}}
+
+	gen := New(Options{
+		Hasher:  hasher,
+		Cache:   &mockCache{data: map[string]Entry{CommandKey(cached): fingerprintOf(t, hasher, nil, cached)}},
+		Finder:  &mockFinder{commands: []Command{cached, executed, failed, cancelled}},
+		Workers: 2,
+	})
+	report, err := gen.Run(context.Background(), tmpDir)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	// 标准输出和标准错误来自不同的管道，两者之间的顺序不确定
+	if out := multi.Errors[0].Output; out != "out\nerr\n" && out != "err\nout\n" {
+		t.Errorf("expected combined output, got %q", out)
+	}
+
+	want := []struct {
+		status Status
+		reason Reason
+	}{
+		{StatusSkipped, ReasonCached},
+		{StatusExecuted, ReasonNew},
+		{StatusFailed, ReasonNew},
+		{StatusCancelled, ""},
+	}
+	for i, w := range want {
+		r := report.Results[i]
+		if r.Status != w.status || r.Reason != w.reason {
+			t.Errorf("result %d: expected %s (%s), got %s (%s)", i, w.status, w.reason, r.Status, r.Reason)
+		}
+	}
+
+	if got := report.Results[1]; string(got.Stdout) != "hello\n" || got.ExitCode != 0 {
+		t.Errorf("unexpected executed result: stdout %q, exit code %d", got.Stdout, got.ExitCode)
+	}
+	if got := report.Results[2]; string(got.Stdout) != "out\n" || string(got.Stderr) != "err\n" || got.ExitCode != 3 {
+		t.Errorf("unexpected failed result: stdout %q, stderr %q, exit code %d", got.Stdout, got.Stderr, got.ExitCode)
+	}
+	if !errors.Is(report.Results[3].Err, ErrDependencyFailed) {
+		t.Errorf("expected dependency error, got %v", report.Results[3].Err)
+	}
+	if report.Count(StatusSkipped) != 1 || report.Count(StatusExecuted) != 1 {
+		t.Errorf("unexpected counts in report %+v", report)
+	}
+}
+
+func TestGeneratorReason(t *testing.T) {
+	tmpDir := t.TempDir()
+	output := filepath.Join(tmpDir, "mock.go")
+	if err := os.WriteFile(output, []byte("package mock"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(tmpDir, "missing.go")
+
+	g := &DefaultGenerator{hasher: &mockHasher{hashes: map[string]string{output: "output-hash"}}}
+	entry := Entry{Source: "s", Command: "c", Tool: "t"}
+
+	tests := []struct {
+		name   string
+		old    Entry
+		exists bool
+		want   Reason
+	}{
+		{"new", Entry{}, false, ReasonNew},
+		{"source", Entry{Source: "x", Command: "c", Tool: "t"}, true, ReasonSourceChanged},
+		{"command", Entry{Source: "s", Command: "x", Tool: "t"}, true, ReasonCommandChanged},
+		{"tool", Entry{Source: "s", Command: "c", Tool: "x"}, true, ReasonToolChanged},
+		{"missing", Entry{Source: "s", Command: "c", Tool: "t", Outputs: map[string]string{missing: "h"}}, true, ReasonOutputMissing},
+		{"modified", Entry{Source: "s", Command: "c", Tool: "t", Outputs: map[string]string{output: "h"}}, true, ReasonOutputModified},
+		{"cached", Entry{Source: "s", Command: "c", Tool: "t", Outputs: map[string]string{output: "output-hash"}}, true, ReasonCached},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.reason(tt.old, tt.exists, entry); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	if got := SortedKeys(map[string]string{"b": "", "a": ""}); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("unexpected sorted outputs %q", got)
+	}
+}
+
+func TestGeneratorPlan(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.go")
+	hasher := &mockHasher{hashes: map[string]string{testFile: "hash"}}
+
+	cached := &mockCommand{path: testFile, line: 1, cmdStr: "mockgen -source=a.go"}
+	changed := &mockCommand{path: testFile, line: 2, cmdStr: "mockgen -source=b.go"}
+	added := &mockCommand{path: testFile, line: 3, cmdStr: "stringer"}
+	previous := &mockCommand{path: testFile, line: 2, cmdStr: "mockgen -source=b.go -package=b"}
+
+	cache := &mockCache{data: map[string]Entry{
+		CommandKey(cached):  fingerprintOf(t, hasher, nil, cached),
+		CommandKey(changed): fingerprintOf(t, hasher, nil, previous),
+	}}
+	gen := New(Options{
+		Hasher: hasher,
+		Cache:  cache,
+		Finder: &mockFinder{commands: []Command{cached, changed, added}},
+	})
+
+	report, err := gen.Plan(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []struct {
+		status Status
+		reason Reason
+	}{
+		{StatusSkipped, ReasonCached},
+		{StatusPlanned, ReasonCommandChanged},
+		{StatusPlanned, ReasonNew},
+	}
+	for i, w := range want {
+		r := report.Results[i]
+		if r.Status != w.status || r.Reason != w.reason {
+			t.Errorf("result %d: expected %s (%s), got %s (%s)", i, w.status, w.reason, r.Status, r.Reason)
+		}
+	}
+
+	for _, cmd := range []*mockCommand{cached, changed, added} {
+		if cmd.executed {
+			t.Errorf("expected %s not to be executed", cmd)
+		}
+	}
+	if len(cache.data) != 2 {
+		t.Errorf("expected cache to be unchanged, got %d entries", len(cache.data))
+	}
+}
@@ -0,0 +1,176 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// dependencies 返回每条指令依赖的指令下标，以及同一目录下在它之前执行的指令下标（没有时为 -1）。
+// 依赖来自包依赖图和 //gogen:after 注解，存在环时返回错误。同一目录下的指令在满足依赖的前提下
+// 按源码顺序串行执行，避免快照互相干扰；这只约束顺序，前一条指令失败不影响后面的指令
+func (g *DefaultGenerator) dependencies(commands []Command) ([][]int, []int, error) {
+	dirs := make([]string, len(commands))
+	byDir := make(map[string][]int)
+	for i, cmd := range commands {
+		dir := filepath.Dir(cmd.GetFilePath())
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		dirs[i] = dir
+		byDir[dir] = append(byDir[dir], i)
+	}
+
+	deps, err := annotated(commands)
+	if err != nil {
+		return nil, nil, err
+	}
+	if g.graph != nil {
+		for i, dir := range dirs {
+			for _, dep := range g.graph.Deps(dir) {
+				deps[i] = append(deps[i], byDir[dep]...)
+			}
+		}
+	}
+
+	order, err := sortCommands(commands, deps)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prev := make([]int, len(commands))
+	last := make(map[string]int)
+	for _, i := range order {
+		prev[i] = -1
+		if j, ok := last[dirs[i]]; ok {
+			prev[i] = j
+		}
+		last[dirs[i]] = i
+	}
+	return deps, prev, nil
+}
+
+// Dependents 返回 keys 中的指令以及直接或间接依赖它们的指令的键，依赖关系与 Run 相同，
+// 来自包依赖图和 //gogen:after 注解；graph 可以为空
+func Dependents(commands []Command, graph PackageGraph, keys map[string]bool) (map[string]bool, error) {
+	deps, _, err := (&DefaultGenerator{graph: graph}).dependencies(commands)
+	if err != nil {
+		return nil, err
+	}
+	dependents := make([][]int, len(commands))
+	for i, ds := range deps {
+		for _, d := range ds {
+			dependents[d] = append(dependents[d], i)
+		}
+	}
+
+	result := make(map[string]bool, len(keys))
+	var queue []int
+	for i, cmd := range commands {
+		if key := CommandKey(cmd); keys[key] {
+			result[key] = true
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range dependents[i] {
+			if key := CommandKey(commands[j]); !result[key] {
+				result[key] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+	return result, nil
+}
+
+// annotated 返回 //gogen:after 注解声明的依赖。注解可以引用 //gogen:id 声明的名称，
+// 也可以引用模式或工具名，此时依赖所有匹配的指令；没有匹配任何指令的引用被忽略，
+// 以便只运行部分生成工具时依赖的指令可以不在本次运行中
+func annotated(commands []Command) ([][]int, error) {
+	ids := make(map[string]int)
+	tools := make([]string, len(commands))
+	for i, cmd := range commands {
+		if id := cmd.GetID(); id != "" {
+			if j, exists := ids[id]; exists {
+				return nil, fmt.Errorf("duplicate id %q: %s and %s", id, CommandLocation(commands[j]), CommandLocation(cmd))
+			}
+			ids[id] = i
+		}
+		if args, err := cmd.Args(); err == nil && len(args) > 0 {
+			tools[i] = filepath.Base(args[0])
+		}
+	}
+
+	deps := make([][]int, len(commands))
+	for i, cmd := range commands {
+		for _, target := range cmd.GetAfter() {
+			if j, ok := ids[target]; ok {
+				deps[i] = append(deps[i], j)
+				continue
+			}
+			for j, other := range commands {
+				if j != i && (other.GetPattern() == target || tools[j] == target) {
+					deps[i] = append(deps[i], j)
+				}
+			}
+		}
+	}
+	return deps, nil
+}
+
+// sortCommands 返回满足依赖的执行顺序，没有依赖关系的指令保持原有顺序，存在环时返回错误
+func sortCommands(commands []Command, deps [][]int) ([]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(commands))
+	order := make([]int, 0, len(commands))
+	var stack []int
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := append(slices.Clone(stack[slices.Index(stack, i):]), i)
+			names := make([]string, len(cycle))
+			for k, j := range cycle {
+				names[k] = describe(commands[j])
+			}
+			return fmt.Errorf("dependency cycle: %s", strings.Join(names, " after "))
+		}
+
+		state[i] = visiting
+		stack = append(stack, i)
+		for _, d := range deps[i] {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[i] = visited
+		order = append(order, i)
+		return nil
+	}
+
+	for i := range commands {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// describe 返回指令在错误信息中的名称
+func describe(cmd Command) string {
+	if id := cmd.GetID(); id != "" {
+		return fmt.Sprintf("%s (%s)", CommandLocation(cmd), id)
+	}
+	return CommandLocation(cmd)
+}
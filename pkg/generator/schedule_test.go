@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSortCommandsAnnotations(t *testing.T) {
+	// 同一目录下 mockgen 写在 protoc 之前，但声明了需要在 protoc 之后执行
+	commands := []Command{
+		&mockCommand{path: "/src/api/a.go", line: 1, cmdStr: "mockgen -source=a.go", after: []string{"protos"}},
+		&mockCommand{path: "/src/api/a.go", line: 2, cmdStr: "protoc --go_out=. a.proto", id: "protos"},
+		&mockCommand{path: "/src/api/b.go", line: 1, cmdStr: "stringer -type=Kind", after: []string{"protoc"}},
+		&mockCommand{path: "/src/api/b.go", line: 2, cmdStr: "enumer", after: []string{"missing"}},
+	}
+
+	deps, err := annotated(commands)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := [][]int{{1}, nil, {1}, nil}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("annotated() = %v, want %v", deps, want)
+	}
+
+	order, err := sortCommands(commands, deps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(order, []int{1, 0, 2, 3}) {
+		t.Errorf("sortCommands() = %v, want %v", order, []int{1, 0, 2, 3})
+	}
+
+	// 同一目录按排序后的顺序串行执行，但不作为依赖
+	all, prev, err := (&DefaultGenerator{}).dependencies(commands)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(all, want) {
+		t.Errorf("dependencies() = %v, want %v", all, want)
+	}
+	if !reflect.DeepEqual(prev, []int{1, -1, 0, 2}) {
+		t.Errorf("dependencies() prev = %v, want %v", prev, []int{1, -1, 0, 2})
+	}
+}
+
+func TestSortCommandsCycle(t *testing.T) {
+	commands := []Command{
+		&mockCommand{path: "/src/a.go", line: 1, id: "a", after: []string{"b"}},
+		&mockCommand{path: "/src/b.go", line: 1, id: "b", after: []string{"a"}},
+	}
+	_, _, err := (&DefaultGenerator{}).dependencies(commands)
+	if err == nil {
+		t.Fatal("expected cycle error but got none")
+	}
+	want := "dependency cycle: /src/a.go:1 (a) after /src/b.go:1 (b) after /src/a.go:1 (a)"
+	if err.Error() != want {
+		t.Errorf("unexpected error %q, want %q", err, want)
+	}
+}
+
+func TestAnnotatedDuplicateID(t *testing.T) {
+	commands := []Command{
+		&mockCommand{path: "/src/a.go", line: 1, id: "gen"},
+		&mockCommand{path: "/src/b.go", line: 1, id: "gen"},
+	}
+	if _, err := annotated(commands); err == nil || !strings.Contains(err.Error(), "duplicate id") {
+		t.Errorf("expected duplicate id error, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package generator
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Changes 记录命令执行前后文件的变化，路径均为绝对路径并按字典序排列
+type Changes struct {
+	Created  []string
+	Modified []string
+	Deleted  []string
+}
+
+// Outputs 返回命令新建或修改的文件
+func (c Changes) Outputs() []string {
+	outputs := make([]string, 0, len(c.Created)+len(c.Modified))
+	outputs = append(outputs, c.Created...)
+	outputs = append(outputs, c.Modified...)
+	sort.Strings(outputs)
+	return outputs
+}
+
+// Empty 判断是否没有任何文件变化
+func (c Changes) Empty() bool {
+	return len(c.Created) == 0 && len(c.Modified) == 0 && len(c.Deleted) == 0
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshot 记录某一时刻文件的大小和修改时间
+type snapshot map[string]fileState
+
+// takeSnapshot 记录 dirs 中直接包含的文件，以及 roots 下递归包含的文件，
+// 递归时跳过隐藏目录，不存在的目录被忽略
+func takeSnapshot(dirs, roots []string) (snapshot, error) {
+	s := make(snapshot)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			if err := s.add(filepath.Join(dir, entry.Name()), entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return s.add(path, d)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s snapshot) add(path string, d fs.DirEntry) error {
+	info, err := d.Info()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	s[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+	return nil
+}
+
+// diff 比较执行前后的快照
+func (s snapshot) diff(after snapshot) Changes {
+	var changes Changes
+	for path, state := range after {
+		before, ok := s[path]
+		switch {
+		case !ok:
+			changes.Created = append(changes.Created, path)
+		case before.size != state.size || !before.modTime.Equal(state.modTime):
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+	for path := range s {
+		if _, ok := after[path]; !ok {
+			changes.Deleted = append(changes.Deleted, path)
+		}
+	}
+
+	sort.Strings(changes.Created)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Deleted)
+	return changes
+}
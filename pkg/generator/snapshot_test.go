@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	outDir := filepath.Join(tmpDir, "out")
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	kept := filepath.Join(tmpDir, "kept.go")
+	modified := filepath.Join(tmpDir, "modified.go")
+	deleted := filepath.Join(tmpDir, "deleted.go")
+	write(kept, "kept")
+	write(modified, "before")
+	write(deleted, "deleted")
+	write(filepath.Join(tmpDir, "sub", "ignored.go"), "ignored")
+
+	before, err := takeSnapshot([]string{tmpDir}, []string{outDir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	created := filepath.Join(tmpDir, "created.go")
+	nested := filepath.Join(outDir, "a", "b.pb.go")
+	write(created, "created")
+	write(nested, "nested")
+	write(modified, "after")
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(modified, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(deleted); err != nil {
+		t.Fatal(err)
+	}
+	// 工作目录不递归，子目录的变化不计入
+	write(filepath.Join(tmpDir, "sub", "other.go"), "other")
+
+	after, err := takeSnapshot([]string{tmpDir}, []string{outDir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := Changes{
+		Created:  []string{created, nested},
+		Modified: []string{modified},
+		Deleted:  []string{deleted},
+	}
+	if got := before.diff(after); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected changes %+v, got %+v", want, got)
+	}
+}
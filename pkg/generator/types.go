@@ -1,10 +1,25 @@
 package generator
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 // Generator 定义代码生成器的核心接口
 type Generator interface {
 	Generate(ctx context.Context, dir string) error
+	// Run 与 Generate 相同，同时返回每条指令的结果
+	Run(ctx context.Context, dir string) (*Report, error)
+	// Plan 只检查每条指令是否需要执行及其原因，不执行任何命令
+	Plan(ctx context.Context, dir string) (*Report, error)
+	// Prune 查找 dir 下的指令，删除 dir 下已不存在的指令的缓存记录，返回删除的键
+	Prune(ctx context.Context, dir string) ([]string, error)
 }
 
 // FileHasher 定义文件哈希计算接口
@@ -13,30 +28,168 @@ type FileHasher interface {
 	IsChanged(path, oldHash string) bool
 }
 
-// Cache 定义缓存接口
+// ToolHasher 定义工具二进制标识接口，找不到工具时返回的错误需包装 exec.ErrNotFound 或 fs.ErrNotExist
+type ToolHasher interface {
+	HashTool(name, dir string) (string, error)
+}
+
+// Cache 定义缓存接口，键为 CommandKey 返回的指令标识
 type Cache interface {
 	Load() error
 	Save() error
-	Get(path string) (string, bool)
-	Set(path, hash string)
+	Get(key string) (Entry, bool)
+	Set(key string, entry Entry)
+	// Keys 返回所有记录的键
+	Keys() []string
+	Delete(key string)
+}
+
+// Entry 定义单条指令的缓存记录，任一部分变化都会使记录失效
+type Entry struct {
+	Source  string // 源文件哈希
+	Command string // 展开后命令行的哈希
+	Tool    string // 工具二进制的哈希
+
+	// Outputs 记录指令生成的文件及其哈希，文件缺失或被修改时重新执行
+	Outputs map[string]string
+}
+
+// Matches 判断两条记录的指纹是否一致，不比较输出文件
+func (e Entry) Matches(other Entry) bool {
+	return e.Source == other.Source &&
+		e.Command == other.Command &&
+		e.Tool == other.Tool
 }
 
 // Command 定义命令接口
 type Command interface {
-	Execute(ctx context.Context) error
+	// Execute 执行命令，命令的标准输出和标准错误分别写入 stdout 和 stderr
+	Execute(ctx context.Context, stdout, stderr io.Writer) error
+	Args() ([]string, error)
+	Outputs() []string
 	GetFilePath() string
+	GetLine() int
+	// GetIndex 返回指令是文件中的第几条 //go:generate 指令，从 1 开始，不含 -command 别名定义
+	GetIndex() int
+	GetPattern() string
+	// GetID 返回指令声明的名称，GetAfter 返回需要先执行的指令的名称、模式或工具名
+	GetID() string
+	GetAfter() []string
 	String() string
 }
 
+// CommandKey 返回指令在缓存中的标识。声明了 //gogen:id 的指令为 "文件路径@名称"，
+// 其他指令为 "文件路径#序号"，序号为指令是文件中的第几条 //go:generate 指令（不含 -command 别名定义）。
+// 键不包含行号，在指令之前增删注释、注解或代码不会改变键
+func CommandKey(cmd Command) string {
+	if id := cmd.GetID(); id != "" {
+		return cmd.GetFilePath() + "@" + id
+	}
+	return fmt.Sprintf("%s#%d", cmd.GetFilePath(), cmd.GetIndex())
+}
+
+// CommandLocation 返回指令的位置 "文件路径:行号"，只用于显示
+func CommandLocation(cmd Command) string {
+	return fmt.Sprintf("%s:%d", cmd.GetFilePath(), cmd.GetLine())
+}
+
+// SplitKey 把 CommandKey 返回的键拆分为文件路径和 "#序号" 或 "@名称" 形式的后缀，
+// 不是该形式时（如输出文件路径）返回原键和空字符串
+func SplitKey(key string) (string, string) {
+	i := strings.LastIndexAny(key, "#@")
+	if i < 0 || i == len(key)-1 || strings.ContainsAny(key[i:], `/\`) {
+		return key, ""
+	}
+	if key[i] == '#' {
+		if _, err := strconv.Atoi(key[i+1:]); err != nil {
+			return key, ""
+		}
+	}
+	return key[:i], key[i:]
+}
+
+// SortedKeys 返回按路径排序的键，同一文件中的指令按序号排列，声明了名称的指令排在之后；
+// 不含后缀的键（如输出文件路径）按路径排序。m 为空时返回 nil
+func SortedKeys[V any](m map[string]V) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, si := SplitKey(keys[i])
+		pj, sj := SplitKey(keys[j])
+		if pi != pj {
+			return pi < pj
+		}
+		ni, nj := keyOrder(si), keyOrder(sj)
+		if ni != nj {
+			return ni < nj
+		}
+		return si < sj
+	})
+	return keys
+}
+
+// keyOrder 返回键后缀的排序位置：没有后缀的排在最前，其次按序号，声明了名称的排在最后
+func keyOrder(suffix string) int {
+	switch {
+	case suffix == "":
+		return -1
+	case suffix[0] == '#':
+		n, _ := strconv.Atoi(suffix[1:])
+		return n
+	default:
+		return math.MaxInt
+	}
+}
+
+// Within 判断 path 是否为 dir 或其下的路径
+func Within(dir, path string) bool {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
 // CommandFinder 定义命令查找接口
 type CommandFinder interface {
 	Find(dir string) ([]Command, error)
 }
 
+// PackageGraph 定义包依赖图接口，Deps 返回目录对应的包直接或间接依赖的本地包目录
+type PackageGraph interface {
+	Deps(dir string) []string
+}
+
 // Options 定义生成器配置选项
 type Options struct {
 	Hasher  FileHasher
+	Tools   ToolHasher // 可选，为空时不跟踪工具版本
 	Cache   Cache
 	Finder  CommandFinder
 	Workers int
+
+	// Limits 按 Command.GetPattern 进一步限制每个模式的并发数，可为空
+	Limits map[string]int
+
+	// Graph 为空时只保证同一目录下的指令按顺序执行，不同包之间不排序
+	Graph PackageGraph
+
+	// Prune 为 true 时，Run 完整运行后删除 dir 下本次没有遇到的指令的缓存记录。
+	// Finder 只返回缓存中部分指令时（例如缓存与其他生成工具共用）不能设置
+	Prune bool
+
+	// RerunDependents 为 true 时，依赖的指令在本次运行中执行并修改了文件后，指令即使指纹没有变化也重新执行，
+	// 原因为 ReasonDependencyChanged。用于 watch：依赖的包重新生成后，依赖它的指令的输入随之变化
+	RerunDependents bool
+
+	// OnStart 在指令满足依赖并获取到 worker 后、计算指纹前调用，可为空
+	OnStart func(cmd Command)
+	// OnResult 在每条指令处理完成后调用，包括跳过、失败和取消的指令，可为空
+	OnResult func(result Result)
 }
@@ -0,0 +1,46 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCommandKey(t *testing.T) {
+	if got := CommandKey(&mockCommand{path: "/src/a.go", line: 3}); got != "/src/a.go#3" {
+		t.Errorf("CommandKey() = %q, want %q", got, "/src/a.go#3")
+	}
+	if got := CommandKey(&mockCommand{path: "/src/a.go", line: 3, id: "protos"}); got != "/src/a.go@protos" {
+		t.Errorf("CommandKey() = %q, want %q", got, "/src/a.go@protos")
+	}
+}
+
+func TestSplitKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		path   string
+		suffix string
+	}{
+		{"/src/a.go#12", "/src/a.go", "#12"},
+		{"/src/a.go@protos", "/src/a.go", "@protos"},
+		{"/src/#tmp/a.go#3", "/src/#tmp/a.go", "#3"},
+		{"/src/mock.go", "/src/mock.go", ""},
+		{"/src/@types/a.go", "/src/@types/a.go", ""},
+		{"/src/a#b.go", "/src/a#b.go", ""},
+	}
+	for _, tt := range tests {
+		if path, suffix := SplitKey(tt.key); path != tt.path || suffix != tt.suffix {
+			t.Errorf("SplitKey(%q) = %q, %q, want %q, %q", tt.key, path, suffix, tt.path, tt.suffix)
+		}
+	}
+}
+
+func TestSortedKeys(t *testing.T) {
+	keys := SortedKeys(map[string]int{"/src/b.go#1": 0, "/src/a.go@gen": 0, "/src/a.go#10": 0, "/src/a.go#9": 0, "/src/a.go": 0})
+	want := []string{"/src/a.go", "/src/a.go#9", "/src/a.go#10", "/src/a.go@gen", "/src/b.go#1"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("SortedKeys() = %v, want %v", keys, want)
+	}
+	if keys := SortedKeys(map[string]string{}); keys != nil {
+		t.Errorf("expected nil for an empty map, got %v", keys)
+	}
+}
@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Graph 记录目录下各包之间的导入关系，实现 generator.PackageGraph 接口
+type Graph struct {
+	// imports 记录每个包目录直接导入的本地包目录
+	imports map[string][]string
+
+	mu   sync.Mutex
+	deps map[string][]string
+}
+
+// listedPackage 对应 go list -json 输出中用到的字段
+type listedPackage struct {
+	Dir        string
+	ImportPath string
+	Imports    []string
+}
+
+// Load 在 dir 下执行 go list 加载包导入关系，只保留 dir 下的包之间的依赖
+func Load(ctx context.Context, dir string) (*Graph, error) {
+	cmd := exec.CommandContext(ctx, "go", "list", "-e", "-json=Dir,ImportPath,Imports", "./...")
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %s: %w", bytes.TrimSpace(stderr.Bytes()), err)
+	}
+	return parse(bytes.NewReader(out))
+}
+
+// parse 解析 go list -json 输出的包列表
+func parse(r io.Reader) (*Graph, error) {
+	var pkgs []listedPackage
+	dec := json.NewDecoder(r)
+	for {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decode go list output: %w", err)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	dirs := make(map[string]string, len(pkgs))
+	for _, pkg := range pkgs {
+		dirs[pkg.ImportPath] = filepath.Clean(pkg.Dir)
+	}
+
+	g := &Graph{
+		imports: make(map[string][]string, len(pkgs)),
+		deps:    make(map[string][]string),
+	}
+	for _, pkg := range pkgs {
+		dir := filepath.Clean(pkg.Dir)
+		for _, path := range pkg.Imports {
+			if dep, ok := dirs[path]; ok && dep != dir {
+				g.imports[dir] = append(g.imports[dir], dep)
+			}
+		}
+	}
+	return g, nil
+}
+
+// Deps 返回目录对应的包直接或间接依赖的本地包目录，按字典序排列
+func (g *Graph) Deps(dir string) []string {
+	dir = filepath.Clean(dir)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if deps, ok := g.deps[dir]; ok {
+		return deps
+	}
+
+	seen := make(map[string]bool)
+	var visit func(string)
+	visit = func(d string) {
+		for _, dep := range g.imports[d] {
+			if !seen[dep] && dep != dir {
+				seen[dep] = true
+				visit(dep)
+			}
+		}
+	}
+	visit(dir)
+
+	deps := make([]string, 0, len(seen))
+	for dep := range seen {
+		deps = append(deps, dep)
+	}
+	sort.Strings(deps)
+	g.deps[dir] = deps
+	return deps
+}
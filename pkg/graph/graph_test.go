@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not installed, skipping tests")
+	}
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module example.com/demo\n\ngo 1.21\n",
+		"a/a.go":     "package a\n\nimport _ \"example.com/demo/b\"\n",
+		"b/b.go":     "package b\n\nimport (\n\t_ \"fmt\"\n\t_ \"example.com/demo/c\"\n)\n",
+		"c/c.go":     "package c\n",
+		"d/d.go":     "package d\n",
+		"c/c_gen.go": "package c\n\nimport _ \"example.com/demo/missing\"\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g, err := Load(context.Background(), tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir := func(name string) string {
+		d, err := filepath.EvalSymlinks(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{dir("a"), []string{dir("b"), dir("c")}},
+		{dir("b"), []string{dir("c")}},
+		{dir("c"), []string{}},
+		{dir("d"), []string{}},
+	}
+	for _, tt := range tests {
+		if got := g.Deps(tt.dir); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Deps(%s) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -1,16 +1,24 @@
 package hash
 
 import (
+	"bytes"
 	"fmt"
 	"hash"
 	"os"
+	"slices"
 	"sync"
 
 	"github.com/cespare/xxhash/v2"
 )
 
+// directivePrefixes 为计算源文件哈希时忽略的行的前缀：go generate 指令和 gogen 注解
+var directivePrefixes = [][]byte{[]byte("//go:generate"), []byte("//gogen:")}
+
 type ContentHasher struct {
 	pool *sync.Pool
+
+	// skipDirectives 为 true 时计算哈希会忽略 //go:generate 指令行和 //gogen: 注解行
+	skipDirectives bool
 }
 
 func NewContentHasher() *ContentHasher {
@@ -23,7 +31,24 @@ func NewContentHasher() *ContentHasher {
 	}
 }
 
+// NewSourceHasher 创建用于源文件的哈希器，计算时忽略 //go:generate 指令行和 //gogen: 注解行，
+// 指令本身的变化由生成器按指令分别跟踪，修改一条指令或注解不会影响同文件的其他指令
+func NewSourceHasher() *ContentHasher {
+	h := NewContentHasher()
+	h.skipDirectives = true
+	return h
+}
+
 func (h *ContentHasher) Hash(path string) (string, error) {
+	return h.hash(path, h.skipDirectives)
+}
+
+// HashWhole 计算整个文件的哈希，不忽略指令行和注解行，与早期版本按文件记录的缓存一致
+func (h *ContentHasher) HashWhole(path string) (string, error) {
+	return h.hash(path, false)
+}
+
+func (h *ContentHasher) hash(path string, skipDirectives bool) (string, error) {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", fmt.Errorf("read file: %w", err)
@@ -35,6 +60,10 @@ func (h *ContentHasher) Hash(path string) (string, error) {
 		h.pool.Put(hasher)
 	}()
 
+	if skipDirectives {
+		content = stripDirectives(content)
+	}
+
 	if _, err := hasher.Write(content); err != nil {
 		return "", fmt.Errorf("hash content: %w", err)
 	}
@@ -49,3 +78,25 @@ func (h *ContentHasher) IsChanged(path, oldHash string) bool {
 	}
 	return newHash != oldHash
 }
+
+// stripDirectives 返回去掉所有 //go:generate 指令行和 //gogen: 注解行后的内容
+func stripDirectives(content []byte) []byte {
+	if !slices.ContainsFunc(directivePrefixes, func(p []byte) bool { return bytes.Contains(content, p) }) {
+		return content
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(content))
+	for len(content) > 0 {
+		line := content
+		if i := bytes.IndexByte(content, '\n'); i >= 0 {
+			line, content = content[:i+1], content[i+1:]
+		} else {
+			content = nil
+		}
+		if !slices.ContainsFunc(directivePrefixes, func(p []byte) bool { return bytes.HasPrefix(line, p) }) {
+			buf.Write(line)
+		}
+	}
+	return buf.Bytes()
+}
@@ -50,3 +50,58 @@ func TestContentHasher(t *testing.T) {
 		t.Error("IsChanged should return true for different content")
 	}
 }
+
+func TestSourceHasher(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "test.go")
+
+	source := "package test\n\n//go:generate mockgen -source=test.go\ntype A interface{}\n"
+	if err := os.WriteFile(testFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := NewSourceHasher()
+	hash1, err := hasher.Hash(testFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 修改指令不影响源文件哈希
+	source = "package test\n\n//go:generate mockgen -source=test.go -destination=mock.go\ntype A interface{}\n"
+	if err := os.WriteFile(testFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hasher.IsChanged(testFile, hash1) {
+		t.Error("directive change should not change source hash")
+	}
+
+	// 添加 gogen 注解不影响源文件哈希
+	source = "package test\n\n//gogen:after protos\n//gogen:timeout 30s\n//go:generate mockgen -source=test.go -destination=mock.go\ntype A interface{}\n"
+	if err := os.WriteFile(testFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hasher.IsChanged(testFile, hash1) {
+		t.Error("annotation change should not change source hash")
+	}
+
+	// 修改代码会改变源文件哈希
+	source = "package test\n\n//go:generate mockgen -source=test.go -destination=mock.go\ntype B interface{}\n"
+	if err := os.WriteFile(testFile, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !hasher.IsChanged(testFile, hash1) {
+		t.Error("code change should change source hash")
+	}
+	// HashWhole 与早期版本一样计算整个文件的哈希
+	whole, err := hasher.HashWhole(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := NewContentHasher().Hash(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if whole != plain {
+		t.Errorf("HashWhole got %s, want full content hash %s", whole, plain)
+	}
+}
@@ -0,0 +1,62 @@
+package hash
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// toolStamp 记录工具二进制的哈希及计算时的文件状态
+type toolStamp struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// toolHashes 缓存已计算的工具哈希，文件大小和修改时间不变时直接复用
+var toolHashes sync.Map
+
+// HashTool 解析工具的可执行文件路径并计算其内容哈希，
+// 工具升级或替换后哈希随之变化，用于使缓存失效
+func (h *ContentHasher) HashTool(name, dir string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) && !filepath.IsAbs(name) {
+		name = filepath.Join(dir, name)
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("resolve tool: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("stat tool: %w", err)
+	}
+	if v, ok := toolHashes.Load(path); ok {
+		stamp := v.(toolStamp)
+		if stamp.size == info.Size() && stamp.modTime.Equal(info.ModTime()) {
+			return stamp.hash, nil
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open tool: %w", err)
+	}
+	defer file.Close()
+
+	hasher := xxhash.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("hash tool: %w", err)
+	}
+
+	sum := fmt.Sprintf("xxhash:%x", hasher.Sum64())
+	toolHashes.Store(path, toolStamp{size: info.Size(), modTime: info.ModTime(), hash: sum})
+	return sum, nil
+}
@@ -0,0 +1,59 @@
+package hash
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHashTool(t *testing.T) {
+	tmpDir := t.TempDir()
+	tool := filepath.Join(tmpDir, "bin", "tool")
+	if err := os.MkdirAll(filepath.Dir(tool), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\necho v1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := NewContentHasher()
+
+	// 相对路径按指令所在目录解析
+	hash1, err := hasher.HashTool(filepath.Join("bin", "tool"), tmpDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 通过 PATH 查找
+	t.Setenv("PATH", filepath.Dir(tool))
+	hash2, err := hasher.HashTool("tool", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hash1 != hash2 {
+		t.Error("same tool should produce same hash")
+	}
+
+	// 工具升级后哈希变化
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\necho v2 upgraded\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(tool, later, later); err != nil {
+		t.Fatal(err)
+	}
+	hash3, err := hasher.HashTool("tool", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hash1 == hash3 {
+		t.Error("upgraded tool should produce different hash")
+	}
+
+	if _, err := hasher.HashTool("gogen-missing-tool", ""); !errors.Is(err, exec.ErrNotFound) {
+		t.Errorf("expected exec.ErrNotFound for missing tool, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+// Package watch 监听目录树中文件的变化
+package watch
+
+import "errors"
+
+// ErrOverflow 表示事件队列溢出，部分变化已经丢失，调用方应重新扫描整个目录树
+var ErrOverflow = errors.New("watch: event queue overflow")
@@ -0,0 +1,185 @@
+//go:build linux
+
+package watch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// mask 为监听的 inotify 事件，写入完成、创建、删除和重命名
+const mask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// Watcher 通过 inotify 递归监听目录树，新建的目录会自动加入监听。
+// Events 输出变化的文件路径，Errors 输出读取事件时的错误
+type Watcher struct {
+	Events chan string
+	Errors chan error
+
+	fd   int
+	file *os.File
+	skip func(dir string) bool
+	done chan struct{}
+
+	mu   sync.Mutex
+	dirs map[int]string // 监听描述符对应的目录
+}
+
+// New 监听 root 及其子目录，skip 返回 true 的目录及其子目录不会被监听，可为空
+func New(root string, skip func(dir string) bool) (*Watcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+
+	w := &Watcher{
+		Events: make(chan string, 64),
+		Errors: make(chan error, 1),
+		fd:     fd,
+		// 非阻塞的描述符由运行时的 poller 管理，Close 时会中断读取
+		file: os.NewFile(uintptr(fd), "inotify"),
+		skip: skip,
+		done: make(chan struct{}),
+		dirs: make(map[int]string),
+	}
+	if _, err := w.addTree(root); err != nil {
+		w.file.Close()
+		return nil, err
+	}
+
+	go w.read()
+	return w, nil
+}
+
+// Close 停止监听并关闭 Events 和 Errors
+func (w *Watcher) Close() error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+	}
+	close(w.done)
+	return w.file.Close()
+}
+
+// addTree 监听 root 及其子目录，返回目录中已有的文件
+func (w *Watcher) addTree(root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 目录在遍历时被删除
+			if path != root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, path)
+			return nil
+		}
+		if path != root && w.skip != nil && w.skip(path) {
+			return filepath.SkipDir
+		}
+
+		wd, err := syscall.InotifyAddWatch(w.fd, path, mask)
+		if err != nil {
+			return fmt.Errorf("watch %s: %w", path, err)
+		}
+		w.mu.Lock()
+		w.dirs[wd] = path
+		w.mu.Unlock()
+		return nil
+	})
+	return files, err
+}
+
+func (w *Watcher) read() {
+	defer close(w.Events)
+	defer close(w.Errors)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.sendError(fmt.Errorf("read inotify events: %w", err))
+			}
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buf[offset:])))
+			events := binary.NativeEndian.Uint32(buf[offset+4:])
+			size := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+			start := offset + syscall.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[start:start+size], "\x00"))
+			offset = start + size
+
+			if !w.handle(wd, events, name) {
+				return
+			}
+		}
+	}
+}
+
+// handle 处理单个事件，Watcher 已关闭时返回 false
+func (w *Watcher) handle(wd int, events uint32, name string) bool {
+	if events&syscall.IN_Q_OVERFLOW != 0 {
+		return w.sendError(ErrOverflow)
+	}
+
+	w.mu.Lock()
+	dir, ok := w.dirs[wd]
+	if events&syscall.IN_IGNORED != 0 {
+		delete(w.dirs, wd)
+	}
+	w.mu.Unlock()
+	if !ok || name == "" {
+		return true
+	}
+
+	path := filepath.Join(dir, name)
+	if events&syscall.IN_ISDIR == 0 {
+		return w.send(path)
+	}
+
+	// 新建或移入的目录加入监听，目录中已有的文件在加入监听前可能已经写入，一并报告
+	if events&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && (w.skip == nil || !w.skip(path)) {
+		files, err := w.addTree(path)
+		if err != nil {
+			return w.sendError(err)
+		}
+		for _, file := range files {
+			if !w.send(file) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (w *Watcher) send(path string) bool {
+	select {
+	case w.Events <- path:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+func (w *Watcher) sendError(err error) bool {
+	select {
+	case w.Errors <- err:
+		return true
+	case <-w.done:
+		return false
+	}
+}
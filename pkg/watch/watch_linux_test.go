@@ -0,0 +1,97 @@
+//go:build linux
+
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// expectEvent 等待指定路径的事件
+func expectEvent(t *testing.T, w *Watcher, path string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-w.Events:
+			if got == path {
+				return
+			}
+		case err := <-w.Errors:
+			t.Fatalf("unexpected error: %v", err)
+		case <-timeout:
+			t.Fatalf("timed out waiting for event on %s", path)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := New(root, func(dir string) bool {
+		return strings.HasPrefix(filepath.Base(dir), ".")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// 写入已有目录中的文件
+	file := filepath.Join(root, "a.go")
+	if err := os.WriteFile(file, []byte("package a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, file)
+
+	// 新建目录后其中的文件同样被监听
+	sub := filepath.Join(root, "pkg", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	nested := filepath.Join(sub, "b.go")
+	if err := os.WriteFile(nested, []byte("package b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, nested)
+
+	// 删除文件
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, w, file)
+
+	// 跳过的目录不产生事件
+	if err := os.WriteFile(filepath.Join(root, ".git", "HEAD"), []byte("ref"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(root, "marker")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for got := range w.Events {
+		if strings.Contains(got, ".git") {
+			t.Fatalf("unexpected event in skipped directory: %s", got)
+		}
+		if got == marker {
+			break
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-w.Events:
+		for ok {
+			_, ok = <-w.Events
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Events to be closed after Close")
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// Watcher 在非 Linux 平台上不可用
+type Watcher struct {
+	Events chan string
+	Errors chan error
+}
+
+// New 在非 Linux 平台上返回错误
+func New(root string, skip func(dir string) bool) (*Watcher, error) {
+	return nil, errors.New("watch: only supported on Linux")
+}
+
+// Close 停止监听
+func (w *Watcher) Close() error {
+	return nil
+}
@@ -38,7 +38,8 @@ func BenchmarkGenerate(b *testing.B) {
 				cache := cache.NewFileCache(cacheFile)
 
 				gen := generator.New(generator.Options{
-					Hasher:  hash.NewContentHasher(),
+					Hasher:  hash.NewSourceHasher(),
+					Tools:   hash.NewContentHasher(),
 					Cache:   cache,
 					Finder:  command.NewFinder("mockgen"),
 					Workers: w,
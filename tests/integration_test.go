@@ -72,7 +72,8 @@ func TestIntegration(t *testing.T) {
 
 			// 创建生成器
 			gen := generator.New(generator.Options{
-				Hasher:  hash.NewContentHasher(),
+				Hasher:  hash.NewSourceHasher(),
+				Tools:   hash.NewContentHasher(),
 				Cache:   cache,
 				Finder:  command.NewFinder(tt.cmd),
 				Workers: 1,